@@ -0,0 +1,119 @@
+package mlock
+
+import (
+	"io"
+	"math/rand"
+	"runtime"
+)
+
+// Allocator allocates Buffers. Code that needs Buffers can accept an Allocator rather
+// than calling Alloc directly, so that the allocation strategy can be replaced, such as
+// by a mock in tests.
+type Allocator interface {
+	Alloc(bytes int) (*Buffer, error)
+}
+
+// AllocatorFunc adapts an ordinary function to the Allocator interface.
+type AllocatorFunc func(bytes int) (*Buffer, error)
+
+// Alloc calls f(bytes).
+func (f AllocatorFunc) Alloc(bytes int) (*Buffer, error) {
+	return f(bytes)
+}
+
+// DefaultAllocator allocates Buffers with the package-level Alloc, without options.
+var DefaultAllocator Allocator = NewAllocator()
+
+// NewAllocator returns an Allocator that allocates Buffers with the package-level Alloc,
+// passing it opts.
+func NewAllocator(opts ...Option) Allocator {
+	return AllocatorFunc(func(bytes int) (*Buffer, error) {
+		return Alloc(bytes, opts...)
+	})
+}
+
+// AllocMany allocates a Buffer of each of the requested sizes. If any allocation fails,
+// every Buffer allocated so far is freed and the error returned, so either all of the
+// Buffers are allocated or none are.
+//
+// AllocMany panics if any of the sizes are not positive.
+func AllocMany(sizes ...int) ([]*Buffer, error) {
+	bs := make([]*Buffer, 0, len(sizes))
+	for _, size := range sizes {
+		b, err := Alloc(size)
+		if err != nil {
+			for _, b := range bs {
+				if e := b.Free(); e != nil {
+					panic(e)
+				}
+			}
+			return nil, err
+		}
+		bs = append(bs, b)
+	}
+	return bs, nil
+}
+
+// AllocFromReader allocates a Buffer of the requested size and fills it from r, which
+// must provide exactly size bytes. Like io.ReadFull, it returns io.EOF if r provides no
+// bytes, and io.ErrUnexpectedEOF if it provides some but fewer than size. If r provides
+// more than size bytes, ErrBufferFull is returned. No Buffer is returned on error.
+//
+// AllocFromReader panics if size is not positive.
+func AllocFromReader(r io.Reader, size int) (b *Buffer, err error) {
+	b, err = Alloc(size)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if e := b.Free(); e != nil {
+			panic(e)
+		}
+		b = nil
+	}()
+
+	n, err := b.ReadFrom(r)
+	switch {
+	case err != nil:
+		return b, err
+	case n == 0:
+		return b, io.EOF
+	case n < int64(size):
+		return b, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// AllocReady allocates a Buffer of the requested size with every protection that keeps
+// its data resident and out of reach applied up front: it is locked with WithLock,
+// excluded from core dumps with WithoutCoreDump, and prefaulted with WithParallelPrefault
+// from GOMAXPROCS workers. No lazy work remains, so first use of the data takes no page
+// faults, which suits latency-critical paths that allocate ahead of time.
+//
+// The cost is paid by AllocReady itself: every page is faulted in and locked before it
+// returns, which takes time proportional to size, and counts all of it towards
+// RLIMIT_MEMLOCK at once. It is only supported on Linux.
+//
+// AllocReady panics if bytes is not positive.
+func AllocReady(bytes int) (*Buffer, error) {
+	return Alloc(bytes, WithLock(), WithoutCoreDump(), WithParallelPrefault(runtime.GOMAXPROCS(0)))
+}
+
+// AllocSeeded allocates a full Buffer of the requested size, filled with bytes from a
+// math/rand source seeded with seed, so that tests get the same non-zero contents on every
+// run. It is for testing only: the contents are predictable from seed, so a Buffer from
+// AllocSeeded must NEVER hold a real secret.
+//
+// AllocSeeded panics if size is not positive.
+func AllocSeeded(size int, seed int64) (*Buffer, error) {
+	b, err := Alloc(size)
+	if err != nil {
+		return nil, err
+	}
+	rand.New(rand.NewSource(seed)).Read(b.data)
+	b.setLen(size)
+	return b, nil
+}
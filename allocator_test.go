@@ -0,0 +1,123 @@
+package mlock
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocator(t *testing.T) {
+	b, err := DefaultAllocator.Alloc(len(text))
+	require.NoError(t, err)
+	require.Equal(t, len(text), b.Cap())
+	require.NoError(t, b.Free())
+
+	b, err = NewAllocator(WithTTL(testTTL)).Alloc(len(text))
+	require.NoError(t, err)
+	require.Equal(t, testTTL, b.opts.ttl)
+	require.NoError(t, b.Free())
+
+	_, err = NewAllocator(WithoutFork(), WithWipeOnFork()).Alloc(len(text))
+	require.EqualError(t, err, ErrConflictingOptions.Error())
+}
+
+func TestAllocatorMock(t *testing.T) {
+	errMock := errors.New("mock allocation failure")
+	var requested []int
+	mock := AllocatorFunc(func(bytes int) (*Buffer, error) {
+		requested = append(requested, bytes)
+		return nil, errMock
+	})
+
+	b, err := allocKey(mock)
+	require.Nil(t, b)
+	require.EqualError(t, err, errMock.Error())
+	require.Equal(t, []int{32}, requested)
+}
+
+// allocKey stands in for code that depends on an Allocator.
+func allocKey(a Allocator) (*Buffer, error) {
+	return a.Alloc(32)
+}
+
+// failingProvider fails the nth call to Mmap.
+type failingProvider struct {
+	*fakeProvider
+	n     int
+	calls int
+}
+
+var errMmap = errors.New("mmap failed")
+
+func (f *failingProvider) Mmap(length int) ([]byte, error) {
+	f.calls++
+	if f.calls == f.n {
+		return nil, errMmap
+	}
+	return f.fakeProvider.Mmap(length)
+}
+
+func TestAllocMany(t *testing.T) {
+	defer assertNoLeaks(t)()
+
+	bs, err := AllocMany(1, kb, 3*kb)
+	require.NoError(t, err)
+	require.Len(t, bs, 3)
+	for i, size := range []int{1, kb, 3 * kb} {
+		require.Equal(t, size, bs[i].Cap())
+	}
+	freeAll(t, bs...)
+
+	f := &failingProvider{fakeProvider: newFakeProvider(), n: 3}
+	SetSyscallProvider(f)
+	defer SetSyscallProvider(nil)
+
+	bs, err = AllocMany(1, kb, 3*kb, 4*kb)
+	require.Nil(t, bs)
+	require.EqualError(t, err, errMmap.Error())
+	require.Len(t, f.mapped, 2)
+	require.Equal(t, f.mapped, f.unmapped)
+}
+
+func TestAllocFromReader(t *testing.T) {
+	defer assertNoLeaks(t)()
+
+	b, err := AllocFromReader(bytes.NewReader(text), len(text))
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+	require.NoError(t, b.Free())
+
+	b, err = AllocFromReader(bytes.NewReader(text), len(text)+1)
+	require.Nil(t, b)
+	require.EqualError(t, err, io.ErrUnexpectedEOF.Error())
+
+	b, err = AllocFromReader(bytes.NewReader(nil), len(text))
+	require.Nil(t, b)
+	require.EqualError(t, err, io.EOF.Error())
+
+	b, err = AllocFromReader(bytes.NewReader(text), len(text)-1)
+	require.Nil(t, b)
+	require.EqualError(t, err, ErrBufferFull.Error())
+
+	b, err = AllocFromReader(&stalledReader{b: text}, len(text)+1)
+	require.Nil(t, b)
+	require.EqualError(t, err, io.ErrNoProgress.Error())
+}
+
+func TestAllocSeeded(t *testing.T) {
+	a, err := AllocSeeded(kb, 42)
+	require.NoError(t, err)
+	b, err := AllocSeeded(kb, 42)
+	require.NoError(t, err)
+	c, err := AllocSeeded(kb, 43)
+	require.NoError(t, err)
+
+	require.Equal(t, kb, a.Len())
+	require.Equal(t, a.View(), b.View())
+	require.NotEqual(t, a.View(), c.View())
+	require.False(t, allZero(a.View()))
+	freeAll(t, a, b, c)
+}
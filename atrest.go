@@ -0,0 +1,125 @@
+package mlock
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// sealer holds the key and IV that a Buffer allocated WithEncryptedAtRest encrypts its
+// data with, in a locked mapping of its own. The data is encrypted with AES-256 in CTR
+// mode, with the counter derived from each byte's offset in the data, so that any range
+// of it can be encrypted or decrypted independently.
+type sealer struct {
+	p   Provider
+	mem []byte // key, then IV
+}
+
+const sealerKeySize = 32
+
+func newSealer(p Provider) (s *sealer, err error) {
+	mem, err := mmap(p, pagesize)
+	if err != nil {
+		return nil, err
+	}
+	s = &sealer{p: p, mem: mem}
+	defer func() {
+		if err != nil {
+			s.free()
+			s = nil
+		}
+	}()
+
+	if err := mlock(mem, false); err != nil {
+		return nil, err
+	}
+	if err := advise(mem, &options{noDump: true}); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(mem[:sealerKeySize+aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// xor encrypts or decrypts p, which starts off bytes into the data, in place.
+func (s *sealer) xor(p []byte, off int) {
+	if len(p) == 0 {
+		return
+	}
+	block, err := aes.NewCipher(s.mem[:sealerKeySize])
+	if err != nil {
+		panic(err)
+	}
+
+	// Add the block index of off to the IV, as a 128-bit big-endian counter.
+	var ctr [aes.BlockSize]byte
+	copy(ctr[:], s.mem[sealerKeySize:])
+	lo := binary.BigEndian.Uint64(ctr[8:])
+	hi := binary.BigEndian.Uint64(ctr[:8])
+	if lo+uint64(off/aes.BlockSize) < lo {
+		hi++
+	}
+	binary.BigEndian.PutUint64(ctr[:8], hi)
+	binary.BigEndian.PutUint64(ctr[8:], lo+uint64(off/aes.BlockSize))
+
+	stream := cipher.NewCTR(block, ctr[:])
+	var skip [aes.BlockSize]byte
+	stream.XORKeyStream(skip[:off%aes.BlockSize], skip[:off%aes.BlockSize])
+	stream.XORKeyStream(p, p)
+}
+
+// rotate replaces the IV, so that data written over bytes that were encrypted under the
+// old one is not encrypted with the same keystream.
+func (s *sealer) rotate() {
+	if _, err := rand.Read(s.mem[sealerKeySize : sealerKeySize+aes.BlockSize]); err != nil {
+		panic(err)
+	}
+}
+
+func (s *sealer) free() {
+	wipe(s.mem)
+	if err := munmap(s.p, s.mem); err != nil {
+		panic(err)
+	}
+}
+
+// unseal decrypts the first n bytes of b's data in place, for a method that needs the
+// plaintext. It must be followed by seal(n), or reseal, before b is unlocked.
+func (b *Buffer) unseal(n int) {
+	if b.sealer != nil && b.buf != nil {
+		b.sealer.xor(b.data[:n], 0)
+	}
+}
+
+// seal encrypts the first n bytes of b's data in place after unseal(n), restoring the
+// ciphertext that unseal decrypted; CTR mode is its own inverse. The data must not have
+// changed in between; see reseal.
+func (b *Buffer) seal(n int) {
+	b.unseal(n)
+}
+
+// reseal is like seal, but encrypts the data under a new IV, for use when the data may
+// have changed since unseal.
+func (b *Buffer) reseal() {
+	if b.sealer != nil && b.buf != nil {
+		b.sealer.rotate()
+		b.sealer.xor(b.data[:b.i], 0)
+	}
+}
+
+// sealRange encrypts b.data[from:to] as it is written, or if to is less than from, because
+// the data has been truncated to to bytes, re-encrypts b.data[:to] under a new IV, so that
+// the truncated bytes' keystream is not reused for the data written over them.
+func (b *Buffer) sealRange(from, to int) {
+	switch {
+	case b.sealer == nil:
+	case to > from:
+		b.sealer.xor(b.data[from:to], from)
+	case to < from:
+		b.sealer.xor(b.data[:to], 0)
+		b.sealer.rotate()
+		b.sealer.xor(b.data[:to], 0)
+	}
+}
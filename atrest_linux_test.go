@@ -0,0 +1,265 @@
+package mlock
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedAtRest(t *testing.T) {
+	b, err := Alloc(kb, WithEncryptedAtRest(), WithChecksum())
+	require.NoError(t, err)
+	b.Strict()
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	ciphertext := append([]byte(nil), b.data[:b.i]...)
+	require.False(t, bytes.Contains(accessible(b), text))
+	require.NoError(t, b.Verify())
+
+	// The raw bytes are plaintext only while a view is open.
+	err = b.WithView(func(data []byte) error {
+		require.Equal(t, text, data)
+		require.True(t, bytes.Contains(accessible(b), text))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, ciphertext, b.data[:b.i])
+
+	// Transform re-encrypts under a new IV.
+	require.NoError(t, b.Transform(func(data []byte) { data[0] = 'T' }))
+	require.NotEqual(t, ciphertext[1:], b.data[1:b.i])
+	require.NoError(t, b.Verify())
+	want := append([]byte("T"), text[1:]...)
+	requireView(t, b, want)
+
+	// Data written into a view is encrypted by SetLen.
+	n := b.Len()
+	copy(b.ViewCap()[n:], "!")
+	require.NoError(t, b.SetLen(n+1))
+	require.NotEqual(t, byte('!'), b.data[n])
+	want = append(want, '!')
+	requireView(t, b, want)
+
+	// Truncating and rewriting does not reuse the keystream.
+	before := append([]byte(nil), b.data[:4]...)
+	require.NoError(t, b.Seek(4))
+	require.NotEqual(t, before, b.data[:4])
+	_, err = b.Write([]byte("XYZ"))
+	require.NoError(t, err)
+	want = append(want[:4], "XYZ"...)
+	requireView(t, b, want)
+
+	r, err := b.Realloc(2 * kb)
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(accessible(r), want))
+	requireView(t, r, want)
+
+	_, err = r.AppendFrom(r)
+	require.NoError(t, err)
+	want = append(want, want...)
+	requireView(t, r, want)
+
+	first, second, err := r.Split(2)
+	require.NoError(t, err)
+	requireView(t, first, want[:2])
+	requireView(t, second, want[2:])
+
+	require.NoError(t, first.Free())
+	require.NoError(t, second.Free())
+	require.Nil(t, first.sealer)
+
+	f, err := Alloc(kb, WithEncryptedAtRest())
+	require.NoError(t, err)
+	require.EqualError(t, f.Finalize(), ErrConflictingOptions.Error())
+	require.NoError(t, f.Free())
+
+	_, err = Alloc(kb, WithEncryptedAtRest(), WithoutSecurity())
+	require.EqualError(t, err, ErrConflictingOptions.Error())
+}
+
+func TestEncryptedAtRestReads(t *testing.T) {
+	a := sealedWith(t, text)
+	c := sealedWith(t, text)
+	plain := allocWith(t, text)
+	defer freeAll(t, c, plain)
+
+	eq, err := a.Equal(c)
+	require.NoError(t, err)
+	require.True(t, eq)
+	eq, err = a.Equal(a)
+	require.NoError(t, err)
+	require.True(t, eq)
+	eq, err = a.EqualFresh(plain, a.Generation())
+	require.NoError(t, err)
+	require.True(t, eq)
+
+	ka, err := a.HKDF(sha256.New, nil, nil, 32)
+	require.NoError(t, err)
+	kp, err := plain.HKDF(sha256.New, nil, nil, 32)
+	require.NoError(t, err)
+	eq, err = ka.Equal(kp)
+	require.NoError(t, err)
+	require.True(t, eq)
+	freeAll(t, ka, kp)
+
+	require.Equal(t, plain.Fingerprint(nil), a.Fingerprint(nil))
+	require.Equal(t, fmt.Sprintf("%x", plain), fmt.Sprintf("%x", a))
+
+	want, err := plain.ShannonEntropy()
+	require.NoError(t, err)
+	h, err := a.ShannonEntropy()
+	require.NoError(t, err)
+	require.Equal(t, want, h)
+
+	out := make([]byte, len(text))
+	_, err = a.CopyOut(out)
+	require.NoError(t, err)
+	require.Equal(t, text, out)
+
+	out, err = io.ReadAll(a.Reader())
+	require.NoError(t, err)
+	require.Equal(t, text, out)
+
+	var w bytes.Buffer
+	_, err = a.WriteTo(&w)
+	require.NoError(t, err)
+	require.Equal(t, text, w.Bytes())
+
+	src, dst := unixConnPair(t, syscall.SOCK_SEQPACKET)
+	_, err = a.WriteToConn(src)
+	require.NoError(t, err)
+	out = make([]byte, len(text)+1)
+	n, err := dst.Read(out)
+	require.NoError(t, err)
+	require.Equal(t, text, out[:n])
+
+	fd, err := a.ToMemfd()
+	require.NoError(t, err)
+	defer syscall.Close(fd)
+	out = make([]byte, len(text))
+	_, err = syscall.Pread(fd, out, 0)
+	require.NoError(t, err)
+	require.Equal(t, text, out)
+
+	// A view would expose the ciphertext, or the plaintext once a later read decrypts it.
+	require.Nil(t, a.View())
+	_, err = a.ViewErr()
+	require.EqualError(t, err, ErrConflictingOptions.Error())
+	for range a.Chunks(1) {
+		t.Fatal("Chunks yielded a view")
+	}
+
+	require.False(t, a.IsZeroed())
+	z := sealedWith(t, make([]byte, 16))
+	require.True(t, z.IsZeroed())
+	freeAll(t, z)
+
+	requireSealed(t, a, text)
+
+	w.Reset()
+	_, err = a.Drain(&w)
+	require.NoError(t, err)
+	require.Equal(t, text, w.Bytes())
+}
+
+func TestEncryptedAtRestWrites(t *testing.T) {
+	x := sealedWith(t, []byte("left secret"))
+	y := sealedWith(t, []byte("right value"))
+	plain := allocWith(t, []byte("plain value"))
+	defer freeAll(t, x, y, plain)
+
+	require.NoError(t, ConditionalSwap(x, y, false))
+	requireSealed(t, x, []byte("left secret"))
+	requireSealed(t, y, []byte("right value"))
+	require.NoError(t, Swap(x, y))
+	requireSealed(t, x, []byte("right value"))
+	requireSealed(t, y, []byte("left secret"))
+	require.NoError(t, Swap(x, plain))
+	requireSealed(t, x, []byte("plain value"))
+	require.Equal(t, []byte("right value"), plain.View())
+	require.NoError(t, Swap(x, x))
+	requireSealed(t, x, []byte("plain value"))
+
+	dst := sealedWith(t, []byte("a longer destination"))
+	defer freeAll(t, dst)
+	require.NoError(t, Select(dst, x, y, true))
+	requireSealed(t, dst, []byte("plain value"))
+	require.NoError(t, Select(dst, x, y, false))
+	requireSealed(t, dst, []byte("left secret"))
+	require.NoError(t, Select(x, x, y, false))
+	requireSealed(t, x, []byte("left secret"))
+	require.NoError(t, Select(y, plain, y, true))
+	requireSealed(t, y, []byte("right value"))
+
+	block, err := aes.NewCipher(make([]byte, 16))
+	require.NoError(t, err)
+	s := sealedWith(t, text[:16])
+	p := allocWith(t, text[:16])
+	defer freeAll(t, s, p)
+	require.NoError(t, s.EncryptBlocks(block))
+	require.NoError(t, p.EncryptBlocks(block))
+	requireSealed(t, s, p.View())
+	require.NoError(t, s.DecryptBlocks(block))
+	requireSealed(t, s, text[:16])
+
+	require.NoError(t, s.Replace(bytes.NewReader(text[:16])))
+	requireSealed(t, s, text[:16])
+
+	// Data pending in a buffered writer is encrypted too.
+	bw := dst.BufferedWriter(kb)
+	_, err = bw.Write([]byte("pending"))
+	require.NoError(t, err)
+	requireSealed(t, bw.(*bufferedWriter).scratch, []byte("pending"))
+	require.NoError(t, bw.Close())
+	requireSealed(t, dst, []byte("left secretpending"))
+
+	ok, err := s.CompareAndWipe([]byte("wrong"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	requireSealed(t, s, text[:16])
+	ok, err = s.CompareAndWipe(text[:16])
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Zero(t, s.Len())
+	require.True(t, s.IsZeroed())
+}
+
+// sealedWith returns a Buffer allocated WithEncryptedAtRest holding data.
+func sealedWith(t *testing.T, data []byte) *Buffer {
+	t.Helper()
+	b, err := Alloc(kb, WithEncryptedAtRest(), WithChecksum())
+	require.NoError(t, err)
+	_, err = b.Write(data)
+	require.NoError(t, err)
+	return b
+}
+
+// requireSealed requires that b's plaintext is want, that it is not held in b's memory
+// outside of a view, and that b is intact.
+func requireSealed(t *testing.T, b *Buffer, want []byte) {
+	t.Helper()
+	require.NoError(t, b.Verify())
+	require.False(t, bytes.Contains(accessible(b), want))
+	requireView(t, b, want)
+}
+
+// accessible returns the memory between b's guard pages.
+func accessible(b *Buffer) []byte {
+	return b.buf[len(b.frontGuard) : len(b.buf)-len(b.rearGuard)]
+}
+
+// requireView requires that b's plaintext is want.
+func requireView(t *testing.T, b *Buffer, want []byte) {
+	t.Helper()
+	require.NoError(t, b.WithView(func(data []byte) error {
+		require.Equal(t, want, data)
+		return nil
+	}))
+}
@@ -0,0 +1,38 @@
+package mlock
+
+import "crypto/cipher"
+
+// EncryptBlocks encrypts each block of the buffer's written data in place with block,
+// independently, as ECB mode would. It is a building block for modes and constructions
+// that need raw block operations, and is not secure as a mode by itself: equal blocks of
+// plaintext give equal blocks of ciphertext. The buffer's length must be a multiple of
+// block.BlockSize(), or ErrBlockSize is returned and nothing is encrypted.
+func (b *Buffer) EncryptBlocks(block cipher.Block) error {
+	return b.cryptBlocks(block.BlockSize(), block.Encrypt)
+}
+
+// DecryptBlocks is EncryptBlocks' inverse, decrypting each block of the buffer's written
+// data in place with block.
+func (b *Buffer) DecryptBlocks(block cipher.Block) error {
+	return b.cryptBlocks(block.BlockSize(), block.Decrypt)
+}
+
+func (b *Buffer) cryptBlocks(size int, crypt func(dst, src []byte)) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+	if b.i%size != 0 {
+		return ErrBlockSize
+	}
+
+	b.unseal(b.i)
+	for i := 0; i < b.i; i += size {
+		p := b.data[i : i+size]
+		crypt(p, p)
+	}
+	b.reseal()
+	b.setLen(b.i)
+	return nil
+}
@@ -0,0 +1,34 @@
+package mlock
+
+import (
+	"crypto/aes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptBlocks(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	require.NoError(t, err)
+
+	plaintext := make([]byte, 4*aes.BlockSize)
+	copy(plaintext, text)
+	copy(plaintext[3*aes.BlockSize:], plaintext[:aes.BlockSize])
+	b := allocWith(t, plaintext)
+
+	require.NoError(t, b.EncryptBlocks(block))
+	want := make([]byte, aes.BlockSize)
+	for i := 0; i < len(plaintext); i += aes.BlockSize {
+		block.Encrypt(want, plaintext[i:i+aes.BlockSize])
+		require.Equal(t, want, b.View()[i:i+aes.BlockSize])
+	}
+	require.NoError(t, b.Verify())
+
+	require.NoError(t, b.DecryptBlocks(block))
+	require.Equal(t, plaintext, b.View())
+
+	require.NoError(t, b.SetLen(len(plaintext)-1))
+	require.EqualError(t, b.EncryptBlocks(block), ErrBlockSize.Error())
+	require.Equal(t, plaintext[:len(plaintext)-1], b.View())
+	freeAll(t, b)
+}
@@ -0,0 +1,113 @@
+package mlock
+
+import "io"
+
+// BufferedWriter returns a writer that collects writes of fewer than blockSize bytes in a
+// scratch Buffer, and appends them to b in blocks, so that many small writes, such as a
+// secret written a rune at a time, cost one integrity check of b per block rather than
+// one per write. The scratch Buffer is allocated on the first write, with b's options so
+// that pending data is protected as b's is, and is wiped after every block and freed by
+// Close.
+//
+// Like bufio.Writer, the writer reports an error from appending to b, such as
+// ErrBufferFull, from the write or Close that triggers it, and every call after that
+// returns the same error. Close appends any remaining data, and the writer must be closed
+// for it to reach b. Writes after Close return ErrAlreadyFreed. The writer is not safe
+// for concurrent use.
+//
+// BufferedWriter panics if blockSize is not positive.
+func (b *Buffer) BufferedWriter(blockSize int) io.WriteCloser {
+	if blockSize <= 0 {
+		panic("non-positive block size requested")
+	}
+	return &bufferedWriter{b: b, size: blockSize}
+}
+
+type bufferedWriter struct {
+	b       *Buffer
+	size    int
+	scratch *Buffer
+	closed  bool
+	err     error
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrAlreadyFreed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.scratch == nil {
+		if w.scratch, w.err = alloc(w.size, w.b.inherited()); w.err != nil {
+			w.scratch = nil
+			return 0, w.err
+		}
+	}
+
+	var total int
+	for len(p) > 0 {
+		s := w.scratch
+		if s.Len() == 0 && len(p) >= w.size {
+			// Nothing is pending, so a whole block can go straight to b.
+			n, err := w.b.Write(p)
+			total += n
+			if err != nil {
+				w.err = err
+				return total, err
+			}
+			return total, nil
+		}
+
+		n, err := s.Write(p)
+		total += n
+		p = p[n:]
+		if err != nil && err != ErrBufferFull {
+			w.err = err
+			return total, err
+		}
+		if s.Len() == s.Cap() {
+			if err := w.flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// flush appends the scratch data to b, and wipes the scratch Buffer.
+func (w *bufferedWriter) flush() error {
+	s := w.scratch
+	if s == nil || s.Len() == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.unlock()
+	if w.err = s.canaryCheck(); w.err != nil {
+		return w.err
+	}
+	s.unseal(s.i)
+	_, w.err = w.b.Write(s.data[:s.i])
+	s.zero()
+	return w.err
+}
+
+// Close appends any remaining data to b, and frees the scratch Buffer.
+func (w *bufferedWriter) Close() error {
+	if w.closed {
+		return ErrAlreadyFreed
+	}
+	w.closed = true
+
+	err := w.err
+	if err == nil {
+		err = w.flush()
+	}
+	if w.scratch != nil {
+		if e := w.scratch.Free(); err == nil {
+			err = e
+		}
+		w.scratch = nil
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package mlock
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	long := make([]byte, 10*kb)
+	_, err := rand.Read(long)
+	require.NoError(t, err)
+
+	for _, block := range []int{1, 7, 64, 4 * kb} {
+		direct, err := Alloc(len(long))
+		require.NoError(t, err)
+		buffered, err := Alloc(len(long))
+		require.NoError(t, err)
+
+		w := buffered.BufferedWriter(block)
+		for p := long; len(p) > 0; {
+			n := min(len(p), rand.Intn(2*block)+1)
+			_, err = direct.Write(p[:n])
+			require.NoError(t, err)
+			written, err := w.Write(p[:n])
+			require.NoError(t, err)
+			require.Equal(t, n, written)
+			p = p[n:]
+		}
+		require.True(t, bytes.HasPrefix(direct.View(), buffered.View()))
+		require.NoError(t, w.Close())
+		require.Equal(t, direct.View(), buffered.View())
+
+		_, err = w.Write(text)
+		require.EqualError(t, err, ErrAlreadyFreed.Error())
+		require.EqualError(t, w.Close(), ErrAlreadyFreed.Error())
+		freeAll(t, direct, buffered)
+	}
+}
+
+func TestBufferedWriterFull(t *testing.T) {
+	defer assertNoLeaks(t)()
+
+	b, err := Alloc(len(text) - 1)
+	require.NoError(t, err)
+	w := b.BufferedWriter(len(text) / 2)
+	for i := range text {
+		_, err = w.Write(text[i : i+1])
+		if err != nil {
+			break
+		}
+	}
+	require.EqualError(t, err, ErrBufferFull.Error())
+	_, err = w.Write(text)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.EqualError(t, w.Close(), ErrBufferFull.Error())
+	require.Equal(t, text[:len(text)-1], b.View())
+	require.NoError(t, b.Free())
+}
+
+func TestBufferedWriterInheritsOptions(t *testing.T) {
+	b, err := Alloc(kb, WithChecksum())
+	require.NoError(t, err)
+	w := b.BufferedWriter(8)
+	_, err = w.Write(text[:4])
+	require.NoError(t, err)
+	require.Equal(t, b.opts, w.(*bufferedWriter).scratch.opts)
+	require.NoError(t, w.Close())
+	require.Equal(t, text[:4], b.View())
+	require.NoError(t, b.Free())
+}
@@ -0,0 +1,40 @@
+package mlock
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildPlatforms covers each provider implementation and the heap fallback on platforms
+// without mmap at all.
+var buildPlatforms = []struct{ goos, goarch string }{
+	{"linux", "amd64"},
+	{"linux", "arm64"},
+	{"darwin", "arm64"},
+	{"freebsd", "amd64"},
+	{"windows", "amd64"},
+	{"plan9", "amd64"},
+	{"js", "wasm"},
+}
+
+func TestBuildMatrix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cross-compiling is slow")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	for _, p := range buildPlatforms {
+		t.Run(p.goos+"/"+p.goarch, func(t *testing.T) {
+			cmd := exec.Command(gobin, "build", ".")
+			cmd.Env = append(os.Environ(), "GOOS="+p.goos, "GOARCH="+p.goarch, "CGO_ENABLED=0")
+			out, err := cmd.CombinedOutput()
+			require.NoError(t, err, "%s", out)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package mlock
+
+import "hash/crc32"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumOK reports whether the written data still matches its checksum. It is always
+// true for buffers allocated without WithChecksum.
+func (b *Buffer) checksumOK() bool {
+	return !b.opts.checksum || crc32.Checksum(b.data[:b.i], castagnoli) == b.sum
+}
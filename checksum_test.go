@@ -0,0 +1,38 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	b, err := Alloc(kb, WithChecksum())
+	require.NoError(t, err)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.NoError(t, b.AppendField(1, text))
+	require.NoError(t, b.Verify())
+
+	require.NoError(t, b.Rewind(len(text)))
+	require.NoError(t, b.Verify())
+
+	// Writing through a view is only accepted once SetLen recomputes the checksum.
+	view := b.ViewCap()
+	view[len(text)] = 'x'
+	require.NoError(t, b.SetLen(len(text)+1))
+	require.NoError(t, b.Verify())
+
+	view[0] ^= 1
+	err = b.Verify()
+	require.EqualError(t, err, ErrDataCorrupted.Error())
+	view[0] ^= 1
+	require.NoError(t, b.Verify())
+
+	b.Zero()
+	require.NoError(t, b.Verify())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
@@ -0,0 +1,31 @@
+package mlock
+
+import "iter"
+
+// Chunks returns an iterator over successive size-byte views of the buffer's written
+// data, for processing it in fixed blocks. The final chunk is shorter if the length is not
+// a multiple of size. Each chunk is a view into the buffer, like those returned by View,
+// and the same rules apply to it.
+//
+// The buffer is checked for integrity once, when iteration starts. If b is corrupt or
+// freed, or was allocated WithEncryptedAtRest, which has no views, the iterator yields
+// nothing; check Verify first to tell this apart from an empty buffer. Chunks panics if
+// size is not positive.
+func (b *Buffer) Chunks(size int) iter.Seq[[]byte] {
+	if size <= 0 {
+		panic("non-positive chunk size")
+	}
+	return func(yield func([]byte) bool) {
+		data, err := b.ViewErr()
+		if err != nil {
+			return
+		}
+		for len(data) > 0 {
+			n := min(size, len(data))
+			if !yield(data[:n:n]) {
+				return
+			}
+			data = data[n:]
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package mlock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunks(t *testing.T) {
+	b := allocWith(t, text)
+
+	for _, size := range []int{1, 7, len(text), len(text) + 1} {
+		var total int
+		var joined []byte
+		for chunk := range b.Chunks(size) {
+			require.True(t, len(chunk) <= size)
+			total += len(chunk)
+			joined = append(joined, chunk...)
+		}
+		require.Equal(t, b.Len(), total)
+		require.Equal(t, text, joined)
+	}
+
+	// Stopping early is respected.
+	var n int
+	for range b.Chunks(1) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	require.Equal(t, 3, n)
+
+	// Chunks are live views.
+	for chunk := range b.Chunks(4) {
+		chunk[0] = 'x'
+	}
+	require.True(t, bytes.HasPrefix(b.View(), []byte("x")))
+
+	freeAll(t, b)
+	for range b.Chunks(4) {
+		t.Fatal("freed buffer yielded a chunk")
+	}
+}
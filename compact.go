@@ -0,0 +1,28 @@
+package mlock
+
+// Compact moves the buffer's data into a new mapping just large enough to hold it, if
+// that would release at least the buffer's compaction threshold of pages (see
+// WithCompactThreshold). In that case it behaves like b.Realloc(b.Len()), and b is freed.
+// Otherwise, Compact does nothing and returns b itself.
+//
+// Compact allows a long-lived buffer to shed locked memory after its contents shrink.
+func (b *Buffer) Compact() (*Buffer, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return nil, err
+	}
+
+	size := b.i
+	if size == 0 {
+		size = 1
+	}
+	threshold := b.opts.compactThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if (len(b.buf)-b.opts.requiredBytes(size))/pagesize < threshold {
+		return b, nil
+	}
+	return b.realloc(size)
+}
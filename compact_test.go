@@ -0,0 +1,37 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	b, err := Alloc(10 * pagesize)
+	require.NoError(t, err)
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	pages := len(b.buf) / pagesize
+
+	c, err := b.Compact()
+	require.NoError(t, err)
+	require.NotEqual(t, b, c)
+	require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+	require.Equal(t, text, c.View())
+	require.Equal(t, RequiredBytes(len(text)), len(c.buf))
+	require.True(t, len(c.buf)/pagesize < pages)
+
+	// Already minimal.
+	c2, err := c.Compact()
+	require.NoError(t, err)
+	require.Equal(t, c, c2)
+	require.NoError(t, c.Free())
+
+	b, err = Alloc(10*pagesize, WithCompactThreshold(20))
+	require.NoError(t, err)
+	c, err = b.Compact()
+	require.NoError(t, err)
+	require.Equal(t, b, c)
+	require.Equal(t, 10*pagesize, c.Cap())
+	require.NoError(t, c.Free())
+}
@@ -0,0 +1,42 @@
+package mlock
+
+// Decommit wipes the buffer's data and resets the write location, like Zero, but does so
+// by releasing every page that holds only data back to the system rather than writing
+// zeros to it. Any data sharing a page with the canary or padding is zeroed as usual.
+// This is much faster than Zero for large buffers, and frees the physical memory until
+// the buffer is used again.
+//
+// The mapping is kept, so the buffer remains usable. On Linux, released pages of the
+// private anonymous mapping read as zeros when next touched, and are only backed by
+// physical memory again once written. On other platforms, and for a buffer allocated
+// WithLock, Decommit is equivalent to Zero. So it is for a buffer from NewSharedBuffer,
+// whose memfd keeps the released pages' contents, and for pages that cannot be released,
+// such as after MlockAll.
+func (b *Buffer) Decommit() error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	// Offsets of the data and of the whole pages within it, relative to b.buf.
+	start := cap(b.buf) - cap(b.data)
+	end := start + len(b.data)
+	first := start + (pagesize-start%pagesize)%pagesize
+	last := end - end%pagesize
+	if first >= last || b.locked > 0 || b.shared {
+		b.zero()
+		return nil
+	}
+
+	wipe(b.data[:first-start])
+	wipe(b.data[last-start:])
+	if err := dropPages(b.buf[first:last]); err != nil {
+		// Pages locked outside of WithLock, such as by MlockAll, cannot be released.
+		b.zero()
+		return nil
+	}
+	retag(b) // dropped pages lose their tags
+	b.setLen(0)
+	return nil
+}
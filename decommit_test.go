@@ -0,0 +1,41 @@
+package mlock
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecommit(t *testing.T) {
+	for _, s := range getSizes() {
+		testDecommit(t, s)
+	}
+	testDecommit(t, 4*pagesize)
+	testDecommit(t, 4*pagesize-CanarySize)
+}
+
+func testDecommit(t *testing.T, size int) {
+	b, err := Alloc(size)
+	require.NoError(t, err)
+	b.Strict()
+
+	long := make([]byte, size)
+	_, err = rand.Read(long)
+	require.NoError(t, err)
+	_, err = b.Write(long)
+	require.NoError(t, err)
+
+	err = b.Decommit()
+	require.NoError(t, err)
+	require.Zero(t, b.Len())
+	require.Equal(t, make([]byte, size), b.data)
+	require.NoError(t, b.Verify())
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
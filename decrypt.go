@@ -0,0 +1,120 @@
+package mlock
+
+import (
+	"crypto/cipher"
+	"io"
+	"os"
+)
+
+// Decryptor decrypts a stream of AEAD-sealed chunks, appending the plaintext of each
+// chunk to a Buffer. The plaintext is only ever written to the Buffer's protected memory.
+type Decryptor struct {
+	b     *Buffer
+	aead  cipher.AEAD
+	nonce func(chunk uint64) []byte
+	chunk uint64
+}
+
+// NewDecryptor returns a Decryptor that appends plaintext to b, starting at its current
+// write index. nonce is called with the index of each chunk, starting at zero, and must
+// return the nonce that chunk was sealed with.
+func NewDecryptor(b *Buffer, aead cipher.AEAD, nonce func(chunk uint64) []byte) *Decryptor {
+	return &Decryptor{
+		b:     b,
+		aead:  aead,
+		nonce: nonce,
+	}
+}
+
+// WriteChunk decrypts ct and appends the plaintext to the Buffer. If the plaintext would
+// not fit in the Buffer, ErrBufferFull is returned. If ct fails to authenticate, the
+// error from the AEAD is returned. In both cases, nothing is appended and the chunk
+// index does not advance. ct must not overlap the Buffer.
+func (d *Decryptor) WriteChunk(ct []byte) error {
+	b := d.b
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	if len(ct)-d.aead.Overhead() > len(b.data)-b.i {
+		return ErrBufferFull
+	}
+
+	// Limit the capacity so that Open can never append past the data section.
+	dst := b.data[b.i:b.i:len(b.data)]
+	pt, err := d.aead.Open(dst, d.nonce(d.chunk), ct, nil)
+	if err != nil {
+		return err
+	}
+	b.advance(len(pt))
+	d.chunk++
+	return nil
+}
+
+// FrameSize is the number of bytes of plaintext in each chunk of a file read by
+// DecryptFileInto, other than the last.
+const FrameSize = 64 * 1024
+
+// DecryptFileInto decrypts the file at path into a new Buffer, whose capacity is the
+// length of the plaintext. The file must be a sequence of chunks sealed by aead with
+// the nonces returned by nonceFn, as for a Decryptor, each holding FrameSize bytes of
+// plaintext but the last, which holds the rest. The file is read one chunk at a time, and
+// each is decrypted directly into the Buffer, so however large the file, its plaintext is
+// never held anywhere else.
+//
+// The chunks are sealed without additional data, so a file truncated at a chunk boundary
+// decrypts successfully to a prefix of the plaintext. If that matters, the caller must
+// check the plaintext's length or contents.
+//
+// DecryptFileInto returns ErrInvalidCiphertext if the file's length cannot be split into
+// chunks, and the error from aead if a chunk fails to authenticate. On error, the Buffer
+// is freed and nothing is returned.
+func DecryptFileInto(path string, aead cipher.AEAD, nonceFn func(chunk uint64) []byte) (*Buffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size, overhead := info.Size(), int64(aead.Overhead())
+	frame := FrameSize + overhead
+	frames := (size + frame - 1) / frame
+	if frames > 0 && size-(frames-1)*frame < overhead {
+		return nil, ErrInvalidCiphertext
+	}
+
+	b, err := Alloc(max(int(size-frames*overhead), 1))
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptFrames(NewDecryptor(b, aead, nonceFn), f, size, frame); err != nil {
+		if e := b.Free(); e != nil {
+			panic(e)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// decryptFrames reads size bytes of chunks from r, each frame bytes long but the last,
+// and writes them to d.
+func decryptFrames(d *Decryptor, r io.Reader, size, frame int64) error {
+	ct := make([]byte, frame)
+	for size > 0 {
+		n := min(size, frame)
+		if _, err := io.ReadFull(r, ct[:n]); err != nil {
+			return err
+		}
+		if err := d.WriteChunk(ct[:n]); err != nil {
+			return err
+		}
+		size -= n
+	}
+	return nil
+}
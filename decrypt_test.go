@@ -0,0 +1,176 @@
+package mlock
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptor(t *testing.T) {
+	for _, s := range sizes {
+		testDecryptor(t, s)
+	}
+}
+
+func testDecryptor(t *testing.T, size int) {
+	aead := newTestAEAD(t)
+
+	plaintext := make([]byte, size)
+	_, err := rand.Read(plaintext)
+	require.NoError(t, err)
+
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	d := NewDecryptor(b, aead, counterNonce)
+	var chunk uint64
+	for _, p := range splitChunks(plaintext, 7) {
+		ct := aead.Seal(nil, counterNonce(chunk), p, nil)
+		chunk++
+		err = d.WriteChunk(ct)
+		require.NoError(t, err)
+	}
+	require.Equal(t, plaintext, b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestDecryptorBufferFull(t *testing.T) {
+	aead := newTestAEAD(t)
+
+	b, err := Alloc(len(text) + len(text)/2)
+	require.NoError(t, err)
+
+	d := NewDecryptor(b, aead, counterNonce)
+	err = d.WriteChunk(aead.Seal(nil, counterNonce(0), text, nil))
+	require.NoError(t, err)
+
+	err = d.WriteChunk(aead.Seal(nil, counterNonce(1), text, nil))
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, text, b.View())
+
+	err = d.WriteChunk(aead.Seal(nil, counterNonce(1), text[:len(text)/2], nil))
+	require.NoError(t, err)
+	require.Equal(t, append(append([]byte{}, text...), text[:len(text)/2]...), b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestDecryptorTampered(t *testing.T) {
+	aead := newTestAEAD(t)
+
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	d := NewDecryptor(b, aead, counterNonce)
+	ct := aead.Seal(nil, counterNonce(0), text, nil)
+	ct[3]++
+	err = d.WriteChunk(ct)
+	require.Error(t, err)
+	require.Empty(t, b.View())
+
+	ct[3]--
+	err = d.WriteChunk(ct)
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestDecryptFileInto(t *testing.T) {
+	aead := newTestAEAD(t)
+	dir := t.TempDir()
+
+	for _, size := range []int{0, 1, FrameSize, 3*FrameSize + 17} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err)
+
+		var file []byte
+		for i, p := range splitFrames(plaintext) {
+			file = aead.Seal(file, counterNonce(uint64(i)), p, nil)
+		}
+		path := filepath.Join(dir, "secret")
+		require.NoError(t, os.WriteFile(path, file, 0o600))
+
+		b, err := DecryptFileInto(path, aead, counterNonce)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, b.View())
+		require.Equal(t, max(size, 1), b.Cap())
+		require.NoError(t, b.Free())
+
+		if size == 0 {
+			continue
+		}
+
+		// Swapping frames breaks their nonces.
+		if size > FrameSize {
+			frame := FrameSize + aead.Overhead()
+			swapped := append(append(append([]byte{}, file[frame:2*frame]...), file[:frame]...), file[2*frame:]...)
+			require.NoError(t, os.WriteFile(path, swapped, 0o600))
+			_, err = DecryptFileInto(path, aead, counterNonce)
+			require.Error(t, err)
+		}
+
+		require.NoError(t, os.WriteFile(path, file[:len(file)-1], 0o600))
+		_, err = DecryptFileInto(path, aead, counterNonce)
+		require.Error(t, err)
+	}
+
+	// Too short to hold even the overhead of a chunk.
+	path := filepath.Join(dir, "short")
+	require.NoError(t, os.WriteFile(path, make([]byte, aead.Overhead()-1), 0o600))
+	_, err := DecryptFileInto(path, aead, counterNonce)
+	require.EqualError(t, err, ErrInvalidCiphertext.Error())
+}
+
+// splitFrames splits b into frames for DecryptFileInto.
+func splitFrames(b []byte) [][]byte {
+	var frames [][]byte
+	for len(b) > 0 {
+		n := min(len(b), FrameSize)
+		frames = append(frames, b[:n])
+		b = b[n:]
+	}
+	return frames
+}
+
+func newTestAEAD(t *testing.T) cipher.AEAD {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return aead
+}
+
+func counterNonce(chunk uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], chunk)
+	return nonce
+}
+
+func splitChunks(b []byte, n int) [][]byte {
+	var chunks [][]byte
+	size := len(b)/n + 1
+	for len(b) > 0 {
+		if len(b) < size {
+			size = len(b)
+		}
+		chunks = append(chunks, b[:size])
+		b = b[size:]
+	}
+	return chunks
+}
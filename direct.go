@@ -0,0 +1,56 @@
+package mlock
+
+import "io"
+
+// ReadFromFileDirect reads the file at path into the buffer's free space with direct I/O,
+// bypassing the page cache, so that the file's contents are not left cached in memory
+// outside the buffer. This is intended for reading encrypted key material whose plaintext
+// will be kept in the buffer.
+//
+// Direct I/O requires the free space, from the current write index to the end of the
+// buffer, to start on a page boundary and span a whole number of pages; otherwise
+// ReadFromFileDirect returns ErrUnaligned. In either layout (see WithDataAtPageStart),
+// this holds for an empty buffer whose capacity is a multiple of the page size. If the
+// file does not fit in the free space, nothing is read and ErrBufferFull is returned.
+//
+// ReadFromFileDirect returns ErrUnsupported on platforms other than Linux. On Linux, the
+// file system must support O_DIRECT.
+func (b *Buffer) ReadFromFileDirect(path string) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	free := b.data[b.i:]
+	if (cap(b.buf)-cap(free))%pagesize != 0 || len(free)%pagesize != 0 {
+		return ErrUnaligned
+	}
+
+	f, err := openDirect(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// A direct read cannot probe for a single extra byte, so check the size up front.
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > int64(len(free)) {
+		return ErrBufferFull
+	}
+
+	for b.i < len(b.data) {
+		n, err := f.Read(b.data[b.i:])
+		b.advance(n)
+		if err == io.EOF || err == nil && n == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
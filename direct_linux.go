@@ -0,0 +1,10 @@
+package mlock
+
+import (
+	"os"
+	"syscall"
+)
+
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|syscall.O_DIRECT, 0)
+}
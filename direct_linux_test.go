@@ -0,0 +1,53 @@
+package mlock
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFromFileDirect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mlock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key := bytes.Repeat(text, pagesize/len(text)+1)
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, key, 0600))
+
+	b, err := Alloc(2 * pagesize)
+	require.NoError(t, err)
+
+	err = b.ReadFromFileDirect(path)
+	if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EINVAL {
+		t.Skip("file system does not support O_DIRECT")
+	}
+	require.NoError(t, err)
+	require.Equal(t, key, b.View())
+
+	// The free space no longer starts on a page boundary.
+	err = b.ReadFromFileDirect(path)
+	require.EqualError(t, err, ErrUnaligned.Error())
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	small, err := Alloc(pagesize)
+	require.NoError(t, err)
+	err = small.ReadFromFileDirect(path)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, 0, small.Len())
+
+	unaligned, err := Alloc(pagesize + 1)
+	require.NoError(t, err)
+	err = unaligned.ReadFromFileDirect(path)
+	require.EqualError(t, err, ErrUnaligned.Error())
+
+	require.NoError(t, small.Free())
+	require.NoError(t, unaligned.Free())
+}
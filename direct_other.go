@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package mlock
+
+import "os"
+
+func openDirect(path string) (*os.File, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,37 @@
+package mlock
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// EncodeBase64 allocates a Buffer holding the standard base64 encoding of b's written
+// data, encoded directly from one Buffer into the other. The new Buffer's capacity is the
+// length of the encoding, and it is allocated with b's options, as for Realloc.
+func (b *Buffer) EncodeBase64() (*Buffer, error) {
+	return b.encode(base64.StdEncoding.EncodedLen, base64.StdEncoding.Encode)
+}
+
+// EncodeHex is like EncodeBase64, but produces the lowercase hexadecimal encoding.
+func (b *Buffer) EncodeHex() (*Buffer, error) {
+	return b.encode(hex.EncodedLen, func(dst, src []byte) { hex.Encode(dst, src) })
+}
+
+func (b *Buffer) encode(encodedLen func(int) int, encode func(dst, src []byte)) (*Buffer, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return nil, err
+	}
+
+	n := encodedLen(b.i)
+	r, err := alloc(max(n, 1), b.inherited())
+	if err != nil {
+		return nil, err
+	}
+	b.unseal(b.i)
+	encode(r.data[:n], b.data[:b.i])
+	b.seal(b.i)
+	r.setLen(n)
+	return r, nil
+}
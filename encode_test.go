@@ -0,0 +1,38 @@
+package mlock
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	b := allocWith(t, text)
+
+	enc, err := b.EncodeBase64()
+	require.NoError(t, err)
+	require.Equal(t, base64.StdEncoding.EncodedLen(len(text)), enc.Cap())
+	dec, err := base64.StdEncoding.DecodeString(string(enc.View()))
+	require.NoError(t, err)
+	require.Equal(t, text, dec)
+	freeAll(t, enc)
+
+	enc, err = b.EncodeHex()
+	require.NoError(t, err)
+	require.Equal(t, 2*len(text), enc.Cap())
+	dec, err = hex.DecodeString(string(enc.View()))
+	require.NoError(t, err)
+	require.Equal(t, text, dec)
+	freeAll(t, enc)
+
+	b.Zero()
+	enc, err = b.EncodeBase64()
+	require.NoError(t, err)
+	require.Zero(t, enc.Len())
+	freeAll(t, enc, b)
+
+	_, err = b.EncodeHex()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
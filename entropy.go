@@ -0,0 +1,40 @@
+package mlock
+
+import "math"
+
+// ShannonEntropy returns the Shannon entropy of the buffer's written data, in bits per
+// byte, computed from the frequency of each byte value. It ranges from 0, for data that
+// is a single repeated byte or empty, to 8. A result near 0 for a generated key suggests
+// a failed random source or a buffer that was never filled.
+//
+// This is an estimate of the data's byte distribution, not of its unpredictability; data
+// such as a counter scores highly. Short data also scores low, since n bytes can have at
+// most log2(n) bits of entropy per byte by this measure.
+//
+// The data is read in place. The byte counts derived from it are wiped before returning.
+func (b *Buffer) ShannonEntropy() (float64, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return 0, err
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+
+	var counts [256]int
+	defer func() { counts = [256]int{} }()
+	for _, c := range b.data[:b.i] {
+		counts[c]++
+	}
+
+	var h float64
+	n := float64(b.i)
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h, nil
+}
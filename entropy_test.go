@@ -0,0 +1,40 @@
+package mlock
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	b, err := Alloc(64 * kb)
+	require.NoError(t, err)
+
+	h, err := b.ShannonEntropy()
+	require.NoError(t, err)
+	require.Zero(t, h)
+
+	require.NoError(t, b.SetLen(b.Cap()))
+	h, err = b.ShannonEntropy()
+	require.NoError(t, err)
+	require.Zero(t, h)
+
+	_, err = rand.Read(b.data)
+	require.NoError(t, err)
+	h, err = b.ShannonEntropy()
+	require.NoError(t, err)
+	require.InDelta(t, 8, h, 0.01)
+
+	// Two equally likely values carry one bit.
+	for i := range b.data {
+		b.data[i] = byte(i % 2)
+	}
+	h, err = b.ShannonEntropy()
+	require.NoError(t, err)
+	require.InDelta(t, 1, h, 1e-9)
+
+	require.NoError(t, b.Free())
+	_, err = b.ShannonEntropy()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
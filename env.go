@@ -0,0 +1,40 @@
+package mlock
+
+import (
+	"os"
+	"syscall"
+)
+
+// FromEnv moves the value of the environment variable key into a new Buffer, whose
+// capacity is the length of the value, and unsets the variable so that it is not
+// inherited by child processes. It returns ErrEnvNotSet if the variable is not set.
+//
+// On Linux, the value is also overwritten with zeros in the environment block the
+// process started with, which is what /proc/self/environ reads, and which the runtime
+// otherwise leaves untouched. This is best effort: the runtime's own copy of the value,
+// and any other copies made before FromEnv is called, for example by os.Getenv, are
+// out of reach, and the variable's name is left in place.
+//
+// Since a Buffer cannot be empty, an empty value gives an empty Buffer with a capacity of
+// one byte.
+func FromEnv(key string) (*Buffer, error) {
+	v, ok := syscall.Getenv(key)
+	if !ok {
+		return nil, ErrEnvNotSet
+	}
+
+	b, err := Alloc(max(len(v), 1))
+	if err != nil {
+		return nil, err
+	}
+	b.setLen(copy(b.data, v))
+
+	if err := os.Unsetenv(key); err != nil {
+		if e := b.Free(); e != nil {
+			panic(e)
+		}
+		return nil, err
+	}
+	scrubEnviron(key)
+	return b, nil
+}
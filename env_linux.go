@@ -0,0 +1,62 @@
+package mlock
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// scrubEnviron zeroes the value of every entry for key in the environment block the
+// process started with, which is what /proc/self/environ reads. The runtime copies the
+// environment at startup, so the block is otherwise never changed. It is written through
+// /proc/self/mem, at the address given by /proc/self/stat.
+func scrubEnviron(key string) {
+	start, ok := environStart()
+	if !ok {
+		return
+	}
+	environ, err := os.ReadFile("/proc/self/environ")
+	if err != nil {
+		return
+	}
+	mem, err := os.OpenFile("/proc/self/mem", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer mem.Close()
+
+	prefix := []byte(key + "=")
+	for off := 0; off < len(environ); {
+		entry := environ[off:]
+		if i := bytes.IndexByte(entry, 0); i >= 0 {
+			entry = entry[:i]
+		}
+		if bytes.HasPrefix(entry, prefix) {
+			value := entry[len(prefix):]
+			clear(value)
+			_, _ = mem.WriteAt(value, int64(start)+int64(off+len(prefix)))
+		}
+		clear(entry)
+		off += len(entry) + 1
+	}
+}
+
+// environStart returns the address of the process's initial environment block.
+func environStart() (uint64, bool) {
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, false
+	}
+	// The command name may contain spaces, so count fields from after it. env_start is
+	// field 50, and the first field after the name is field 3.
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 48 {
+		return 0, false
+	}
+	start, err := strconv.ParseUint(string(fields[47]), 10, 64)
+	return start, err == nil && start != 0
+}
@@ -0,0 +1,39 @@
+package mlock
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const envSecret = "correct horse battery staple"
+
+// TestFromEnvScrubsEnviron runs itself in a child process started with the secret in its
+// environment, since only a variable present at startup is in /proc/self/environ.
+func TestFromEnvScrubsEnviron(t *testing.T) {
+	if os.Getenv("MLOCK_TEST_ENVIRON_CHILD") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFromEnvScrubsEnviron$")
+		cmd.Env = append(os.Environ(), "MLOCK_TEST_ENVIRON_CHILD=1", "MLOCK_TEST_SECRET="+envSecret)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return
+	}
+
+	environ, err := os.ReadFile("/proc/self/environ")
+	require.NoError(t, err)
+	require.Contains(t, string(environ), envSecret)
+
+	b, err := FromEnv("MLOCK_TEST_SECRET")
+	require.NoError(t, err)
+	require.Equal(t, envSecret, string(b.View()))
+	require.NoError(t, b.Free())
+
+	environ, err = os.ReadFile("/proc/self/environ")
+	require.NoError(t, err)
+	require.NotContains(t, string(environ), envSecret)
+	require.Contains(t, string(environ), "MLOCK_TEST_SECRET=\x00")
+	require.True(t, bytes.Contains(environ, []byte("MLOCK_TEST_ENVIRON_CHILD=1")))
+}
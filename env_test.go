@@ -0,0 +1,30 @@
+package mlock
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromEnv(t *testing.T) {
+	const key = "MLOCK_TEST_FROM_ENV"
+	t.Setenv(key, string(text))
+
+	b, err := FromEnv(key)
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+	require.Equal(t, len(text), b.Cap())
+	_, ok := os.LookupEnv(key)
+	require.False(t, ok)
+	require.NoError(t, b.Free())
+
+	_, err = FromEnv(key)
+	require.EqualError(t, err, ErrEnvNotSet.Error())
+
+	t.Setenv(key, "")
+	b, err = FromEnv(key)
+	require.NoError(t, err)
+	require.Zero(t, b.Len())
+	require.NoError(t, b.Free())
+}
@@ -0,0 +1,32 @@
+package mlock
+
+// Finalize makes the buffer's data permanently read-only, for secrets such as keys that
+// are written once at startup and never changed. The pages holding the data are
+// protected with PROT_READ, so a stray write through a view faults, and every method
+// that would change the data returns ErrFinalized from then on. There is no way to undo
+// Finalize: even if the pages were made writable again, the methods would still refuse
+// to write.
+//
+// A finalized buffer can still be wiped, by Zero, ZeroErr, expiry or Free, which is
+// always allowed. Finalizing an already finalized buffer does nothing. A Buffer allocated
+// WithEncryptedAtRest cannot be finalized, since its data is decrypted in place to be
+// read, and Finalize returns ErrConflictingOptions.
+func (b *Buffer) Finalize() error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+	if b.finalized {
+		return nil
+	}
+	if b.sealer != nil {
+		return ErrConflictingOptions
+	}
+
+	if err := mprotect(b.p, b.pages(b.data), protRead); err != nil {
+		return err
+	}
+	b.finalized = true
+	return nil
+}
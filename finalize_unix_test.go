@@ -0,0 +1,70 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package mlock
+
+import (
+	"runtime/debug"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFaults reports whether writing to the first byte of p faults.
+func writeFaults(p []byte) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			faulted = true
+		}
+	}()
+
+	p[0] = 1
+	return false
+}
+
+func TestFinalize(t *testing.T) {
+	b := allocWith(t, text)
+	require.NoError(t, b.Finalize())
+	require.NoError(t, b.Finalize())
+	require.NoError(t, b.Verify())
+	require.Equal(t, text, b.View())
+
+	require.True(t, writeFaults(b.View()))
+	_, err := b.Write(text)
+	require.EqualError(t, err, ErrFinalized.Error())
+	require.EqualError(t, b.SetLen(0), ErrFinalized.Error())
+	require.EqualError(t, b.AppendField(1, nil), ErrFinalized.Error())
+
+	// Making the pages writable again does not undo Finalize.
+	require.NoError(t, syscall.Mprotect(b.pages(b.data), syscall.PROT_READ|syscall.PROT_WRITE))
+	_, err = b.Write(text)
+	require.EqualError(t, err, ErrFinalized.Error())
+	require.EqualError(t, b.Rewind(0), ErrFinalized.Error())
+	require.NoError(t, syscall.Mprotect(b.pages(b.data), syscall.PROT_READ))
+
+	// Wiping is still allowed, and leaves the data read-only.
+	require.NoError(t, b.ZeroErr())
+	require.Equal(t, 0, b.Len())
+	require.True(t, writeFaults(b.ViewCap()))
+	_, err = b.Write(text)
+	require.EqualError(t, err, ErrFinalized.Error())
+	freeAll(t, b)
+
+	b = allocWith(t, text)
+	require.NoError(t, b.Finalize())
+	freeAll(t, b)
+}
+
+func TestCompareAndWipeFinalized(t *testing.T) {
+	b := allocWith(t, text)
+	require.NoError(t, b.Finalize())
+
+	ok, err := b.CompareAndWipe(text)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 0, b.Len())
+	require.True(t, writeFaults(b.ViewCap()))
+	freeAll(t, b)
+}
@@ -0,0 +1,95 @@
+package mlock
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+// formatKey keys the digest printed by the %x verb, so that digests can be compared within
+// a process, but cannot be used to guess the data offline.
+var formatKey = func() []byte {
+	k := make([]byte, sha256.Size)
+	if _, err := rand.Read(k); err != nil {
+		panic(err)
+	}
+	return k
+}()
+
+var _ fmt.Formatter = (*Buffer)(nil)
+
+// Format implements fmt.Formatter, so that no formatting verb can print the buffer's
+// data. %v, %s and %q print the buffer's length and capacity, with %+v also printing its
+// state; %d prints its length; and %x and %X print a digest of its data, keyed with a
+// random per-process key, so that two buffers printed by the same process can be seen to
+// hold the same data.
+func (b *Buffer) Format(f fmt.State, verb rune) {
+	if b == nil {
+		fmt.Fprint(f, "<nil>")
+		return
+	}
+
+	b.mu.Lock()
+	defer b.unlock()
+	err := b.canaryCheck()
+
+	switch verb {
+	case 'd':
+		fmt.Fprint(f, b.i)
+	case 'x', 'X':
+		if err != nil {
+			fmt.Fprintf(f, "<%v>", err)
+			return
+		}
+		mac := hmac.New(sha256.New, formatKey)
+		b.unseal(b.i)
+		mac.Write(b.data[:b.i])
+		b.seal(b.i)
+		fmt.Fprintf(f, "%"+string(verb), mac.Sum(nil)[:8])
+	default:
+		s := "mlock.Buffer{len: " + strconv.Itoa(b.i) + ", cap: " + strconv.Itoa(len(b.data))
+		if f.Flag('+') {
+			state := "ok"
+			if err != nil {
+				state = err.Error()
+			}
+			s += ", strict: " + strconv.FormatBool(b.strict) + ", state: " + strconv.Quote(state)
+		}
+		s += "}"
+		if verb == 'q' {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprint(f, s)
+	}
+}
+
+// Fingerprint returns a short hex fingerprint of the buffer's data, a BLAKE2s-256 MAC
+// keyed with key and truncated to 8 bytes, which is safe to log. Unlike the digest
+// printed by Format's %x verb, it is stable across processes that share key, so it can
+// correlate which secret is in use across logs. Keying the hash means that the
+// fingerprint cannot be used to guess the data offline without key, which should itself
+// be kept secret. If the buffer fails its integrity check, the error is returned in angle
+// brackets instead, as Format prints it.
+//
+// Fingerprint panics if key is longer than 32 bytes.
+func (b *Buffer) Fingerprint(key []byte) string {
+	mac, err := blake2s.New256(key)
+	if err != nil {
+		panic(err)
+	}
+
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return "<" + err.Error() + ">"
+	}
+	b.unseal(b.i)
+	mac.Write(b.data[:b.i])
+	b.seal(b.i)
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
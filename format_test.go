@@ -0,0 +1,53 @@
+package mlock
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	a := allocWith(t, text)
+	b := allocWith(t, text)
+	defer freeAll(t, a, b)
+
+	for _, verb := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "% x", "%d", "%T"} {
+		out := fmt.Sprintf(verb, a)
+		require.False(t, bytes.Contains([]byte(out), text), verb)
+		require.NotContains(t, out, hex.EncodeToString(text), verb)
+	}
+
+	require.Equal(t, fmt.Sprintf("mlock.Buffer{len: %d, cap: %d}", len(text), len(text)), fmt.Sprint(a))
+	require.Contains(t, fmt.Sprintf("%+v", a), `state: "ok"`)
+	require.Equal(t, fmt.Sprint(len(text)), fmt.Sprintf("%d", a))
+	require.Equal(t, fmt.Sprintf("%x", a), fmt.Sprintf("%x", b))
+	require.Len(t, fmt.Sprintf("%x", a), 16)
+
+	b.ViewCap()[0] ^= 1
+	require.NotEqual(t, fmt.Sprintf("%x", a), fmt.Sprintf("%x", b))
+
+	var nilBuf *Buffer
+	require.Equal(t, "<nil>", fmt.Sprint(nilBuf))
+}
+
+func TestFingerprint(t *testing.T) {
+	key := []byte("fingerprint key")
+	a := allocWith(t, text)
+	b := allocWith(t, text)
+	c := allocWith(t, text[1:])
+
+	fp := a.Fingerprint(key)
+	require.Len(t, fp, 16)
+	require.Equal(t, fp, b.Fingerprint(key))
+	require.NotEqual(t, fp, c.Fingerprint(key))
+	require.NotEqual(t, fp, a.Fingerprint([]byte("another key")))
+	require.NotContains(t, fp, fmt.Sprintf("%x", text[:8]))
+
+	require.Panics(t, func() { a.Fingerprint(make([]byte, 33)) })
+
+	freeAll(t, a, b, c)
+	require.Equal(t, "<"+ErrAlreadyFreed.Error()+">", a.Fingerprint(key))
+}
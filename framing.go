@@ -0,0 +1,95 @@
+package mlock
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// WriteLengthPrefix writes the length of the buffer's data to w as a 4-byte big-endian
+// integer, for framing the data in a serialized form. The data itself is not written.
+// WriteLengthPrefix returns ErrLengthOverflow if the length does not fit in 32 bits.
+func (b *Buffer) WriteLengthPrefix(w io.Writer) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+	if uint64(b.i) > math.MaxUint32 {
+		return ErrLengthOverflow
+	}
+
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(b.i))
+	_, err := w.Write(prefix[:])
+	return err
+}
+
+// AllocFromPrefixed reads a 4-byte big-endian length from r, as written by
+// WriteLengthPrefix, then allocates a Buffer of exactly that size and fills it with that
+// many bytes from r. Nothing past the payload is read. The length is checked against
+// maxLen before anything is allocated, so that a hostile peer cannot claim a huge length
+// to exhaust memory; a length greater than maxLen returns ErrLengthPrefix.
+//
+// Since a Buffer cannot be empty, a length of zero gives an empty Buffer with a capacity
+// of one byte.
+//
+// Like io.ReadFull, AllocFromPrefixed returns io.EOF if r ends before the prefix, and
+// io.ErrUnexpectedEOF if it ends part way through the prefix or payload. No Buffer is
+// returned on error.
+func AllocFromPrefixed(r io.Reader, maxLen int) (b *Buffer, err error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	if uint64(n) > uint64(max(maxLen, 0)) {
+		return nil, ErrLengthPrefix
+	}
+
+	b, err = Alloc(max(int(n), 1))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if e := b.Free(); e != nil {
+			panic(e)
+		}
+		b = nil
+	}()
+
+	read, err := b.ReadFrom(io.LimitReader(r, int64(n)))
+	switch {
+	case err != nil:
+		return b, err
+	case read < int64(n):
+		return b, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// AppendField writes a tag-length-value field to the buffer: tag, the length of data as an
+// unsigned varint, then data itself. The field is written in full or not at all; if it
+// does not fit in the remaining capacity, AppendField writes nothing and returns
+// ErrBufferFull.
+func (b *Buffer) AppendField(tag byte, data []byte) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	var header [1 + binary.MaxVarintLen64]byte
+	header[0] = tag
+	n := 1 + binary.PutUvarint(header[1:], uint64(len(data)))
+	if n+len(data) > len(b.data)-b.i {
+		return ErrBufferFull
+	}
+
+	b.advance(copy(b.data[b.i:], header[:n]))
+	b.advance(copy(b.data[b.i:], data))
+	return nil
+}
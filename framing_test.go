@@ -0,0 +1,126 @@
+package mlock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLengthPrefix(t *testing.T) {
+	b, err := Alloc(kb)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = b.WriteLengthPrefix(&out)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 0}, out.Bytes())
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.Equal(t, len(text), b.Len())
+
+	out.Reset()
+	err = b.WriteLengthPrefix(&out)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, byte(len(text))}, out.Bytes())
+
+	// Only a 64-bit int can hold a length too long for the prefix.
+	if strconv.IntSize == 64 {
+		b.i = math.MaxInt
+		err = b.WriteLengthPrefix(&out)
+		require.EqualError(t, err, ErrLengthOverflow.Error())
+		b.i = 0
+	}
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	err = b.WriteLengthPrefix(&out)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestAppendField(t *testing.T) {
+	b, err := Alloc(len(text) + 8)
+	require.NoError(t, err)
+
+	err = b.AppendField(1, text)
+	require.NoError(t, err)
+	err = b.AppendField(2, nil)
+	require.NoError(t, err)
+
+	// A field that only partly fits is not written at all.
+	err = b.AppendField(3, []byte("too long"))
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, len(text)+4, b.Len())
+
+	var tags []byte
+	var values [][]byte
+	for view := b.View(); len(view) > 0; {
+		tags = append(tags, view[0])
+		n, m := binary.Uvarint(view[1:])
+		require.True(t, m > 0)
+		view = view[1+m:]
+		values = append(values, view[:n])
+		view = view[n:]
+	}
+	require.Equal(t, []byte{1, 2}, tags)
+	require.Equal(t, [][]byte{text, {}}, values)
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	err = b.AppendField(1, text)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestAllocFromPrefixed(t *testing.T) {
+	var in bytes.Buffer
+	prefixed := func(n uint32, payload []byte) *bytes.Buffer {
+		in.Reset()
+		var prefix [4]byte
+		binary.BigEndian.PutUint32(prefix[:], n)
+		in.Write(prefix[:])
+		in.Write(payload)
+		return &in
+	}
+
+	r := prefixed(uint32(len(text)), append(text, "next"...))
+	b, err := AllocFromPrefixed(r, kb)
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+	require.Equal(t, len(text), b.Cap())
+	require.Equal(t, "next", r.String())
+	require.NoError(t, b.Free())
+
+	// Oversized lengths are rejected before anything more is read.
+	r = prefixed(1<<31, text)
+	_, err = AllocFromPrefixed(r, kb)
+	require.EqualError(t, err, ErrLengthPrefix.Error())
+	require.Equal(t, text, r.Bytes())
+
+	// An empty Buffer's prefix reads back as an empty Buffer.
+	empty, err := Alloc(kb)
+	require.NoError(t, err)
+	in.Reset()
+	require.NoError(t, empty.WriteLengthPrefix(&in))
+	require.NoError(t, empty.Free())
+	b, err = AllocFromPrefixed(&in, kb)
+	require.NoError(t, err)
+	require.Equal(t, 0, b.Len())
+	require.Equal(t, 1, b.Cap())
+	require.NoError(t, b.Free())
+
+	_, err = AllocFromPrefixed(prefixed(uint32(len(text)), text[:5]), kb)
+	require.EqualError(t, err, io.ErrUnexpectedEOF.Error())
+
+	_, err = AllocFromPrefixed(bytes.NewReader([]byte{0, 0}), kb)
+	require.EqualError(t, err, io.ErrUnexpectedEOF.Error())
+
+	_, err = AllocFromPrefixed(bytes.NewReader(nil), kb)
+	require.EqualError(t, err, io.EOF.Error())
+}
@@ -0,0 +1,66 @@
+package mlock
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// SelfTestGuards attempts to read the buffer's front and rear guard pages, and returns
+// ErrGuardAccessible if either read succeeds instead of faulting. It can be called at
+// startup to confirm that guard pages are actually enforced on this system.
+//
+// The faults are caught by enabling debug.SetPanicOnFault on the calling goroutine for
+// the duration of the call, so no subprocess or signal handler is needed.
+func (b *Buffer) SelfTestGuards() error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+
+	if !b.guardsFault() {
+		return ErrGuardAccessible
+	}
+	return nil
+}
+
+// guardsFault reports whether reading every guard page faults, as it should.
+func (b *Buffer) guardsFault() bool {
+	for _, guard := range [][]byte{b.frontGuard, b.rearGuard} {
+		for off := 0; off < len(guard); off += pagesize {
+			if !faults(guard[off:]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkGuards probes the guard pages for a Buffer allocated WithGuardVerify, if they have
+// not been probed within the Buffer's interval.
+func (b *Buffer) checkGuards() error {
+	if !b.opts.guardVerify || time.Since(b.guardsChecked) < b.opts.guardVerifyEvery {
+		return nil
+	}
+	if !b.guardsFault() {
+		b.event = EventCorruption
+		return ErrGuardAccessible
+	}
+	b.guardsChecked = time.Now()
+	return nil
+}
+
+// faults reports whether reading the first byte of page faults.
+func faults(page []byte) (faulted bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			faulted = true
+		}
+	}()
+
+	v := page[0]
+	runtime.KeepAlive(v) // keeps the read from being optimized away
+	return false
+}
@@ -0,0 +1,119 @@
+package mlock
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// guardsProtected reports whether all of b's guard pages are mapped PROT_NONE, according
+// to /proc/self/maps. Unlike SelfTestGuards, it never touches the guards.
+func (b *Buffer) guardsProtected() (bool, error) {
+	f, err := os.Open("/proc/self/maps")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	type region struct{ start, end uintptr }
+	var guards []region
+	for _, g := range [][]byte{b.frontGuard, b.rearGuard} {
+		start := uintptr(unsafe.Pointer(&g[0]))
+		guards = append(guards, region{start, start + uintptr(len(g))})
+	}
+
+	covered := make([]bool, len(guards))
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// start-end perms offset dev inode path
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		bounds := strings.SplitN(fields[0], "-", 2)
+		start, err := strconv.ParseUint(bounds[0], 16, 64)
+		if err != nil {
+			return false, err
+		}
+		end, err := strconv.ParseUint(bounds[1], 16, 64)
+		if err != nil {
+			return false, err
+		}
+
+		for i, g := range guards {
+			if g.start >= uintptr(end) || g.end <= uintptr(start) {
+				continue
+			}
+			if !strings.HasPrefix(fields[1], "---") {
+				return false, nil
+			}
+			if g.start >= uintptr(start) && g.end <= uintptr(end) {
+				covered[i] = true
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return false, err
+	}
+
+	for _, c := range covered {
+		if !c {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func TestGuardsProtected(t *testing.T) {
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Free()) }()
+
+	ok, err := b.guardsProtected()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for _, guard := range [][]byte{b.frontGuard, b.rearGuard} {
+		err = syscall.Mprotect(guard, syscall.PROT_READ)
+		require.NoError(t, err)
+		ok, err = b.guardsProtected()
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		err = syscall.Mprotect(guard, syscall.PROT_NONE)
+		require.NoError(t, err)
+		ok, err = b.guardsProtected()
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+}
+
+func TestWithGuardPages(t *testing.T) {
+	b, err := Alloc(len(text), WithGuardPages(2, 3))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Free()) }()
+
+	require.Len(t, b.buf, RequiredBytes(len(text))+3*pagesize)
+	require.Len(t, b.frontGuard, 2*pagesize)
+	require.Len(t, b.rearGuard, 3*pagesize)
+
+	ok, err := b.guardsProtected()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, b.SelfTestGuards())
+
+	_, err = b.Write([]byte(text))
+	require.NoError(t, err)
+	require.NoError(t, b.Verify())
+
+	for _, bad := range [][2]int{{0, 1}, {1, 0}, {-1, 2}} {
+		_, err = Alloc(len(text), WithGuardPages(bad[0], bad[1]))
+		require.EqualError(t, err, ErrGuardPages.Error())
+	}
+}
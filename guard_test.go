@@ -0,0 +1,21 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTestGuardsFakeProvider(t *testing.T) {
+	SetSyscallProvider(newFakeProvider())
+	defer SetSyscallProvider(nil)
+
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	err = b.SelfTestGuards()
+	require.EqualError(t, err, ErrGuardAccessible.Error())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
@@ -0,0 +1,96 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package mlock
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTestGuards(t *testing.T) {
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	err = b.SelfTestGuards()
+	require.NoError(t, err)
+
+	for _, guard := range [][]byte{b.frontGuard, b.rearGuard} {
+		err = syscall.Mprotect(guard, syscall.PROT_READ)
+		require.NoError(t, err)
+		err = b.SelfTestGuards()
+		require.EqualError(t, err, ErrGuardAccessible.Error())
+
+		err = syscall.Mprotect(guard, syscall.PROT_NONE)
+		require.NoError(t, err)
+		err = b.SelfTestGuards()
+		require.NoError(t, err)
+	}
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	err = b.SelfTestGuards()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestSelfTestGuardsConcurrent(t *testing.T) {
+	// Probes of different buffers run in parallel, and a probe of a readable guard
+	// completes its read; run with -race.
+	errs := make(chan error)
+	for range 8 {
+		go func() {
+			b, err := Alloc(len(text))
+			if err != nil {
+				errs <- err
+				return
+			}
+			err = syscall.Mprotect(b.rearGuard, syscall.PROT_READ)
+			for i := 0; err == nil && i < 5000; i++ {
+				if err = b.SelfTestGuards(); err == ErrGuardAccessible {
+					err = nil
+				}
+			}
+			if err == nil {
+				err = syscall.Mprotect(b.rearGuard, syscall.PROT_NONE)
+			}
+			if e := b.Free(); err == nil {
+				err = e
+			}
+			errs <- err
+		}()
+	}
+	for range 8 {
+		require.NoError(t, <-errs)
+	}
+}
+
+func TestGuardVerify(t *testing.T) {
+	b, err := Alloc(len(text), WithGuardVerify(0))
+	require.NoError(t, err)
+	require.NoError(t, b.Verify())
+
+	for _, guard := range [][]byte{b.frontGuard, b.rearGuard} {
+		err = syscall.Mprotect(guard, syscall.PROT_READ)
+		require.NoError(t, err)
+		err = b.Verify()
+		require.EqualError(t, err, ErrGuardAccessible.Error())
+
+		err = syscall.Mprotect(guard, syscall.PROT_NONE)
+		require.NoError(t, err)
+		require.NoError(t, b.Verify())
+	}
+	require.NoError(t, b.Free())
+
+	// Within the interval, the guards are not probed again.
+	b, err = Alloc(len(text), WithGuardVerify(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, b.Verify())
+	err = syscall.Mprotect(b.rearGuard, syscall.PROT_READ)
+	require.NoError(t, err)
+	require.NoError(t, b.Verify())
+	require.NoError(t, b.Free())
+}
@@ -0,0 +1,43 @@
+package mlock
+
+import (
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// HKDF derives outLen bytes of key material from the buffer's data with HKDF (RFC 5869),
+// using hashFn, salt and info, and returns them in a newly allocated Buffer that must be
+// freed by the caller, and is allocated with b's options. Neither the input nor the
+// derived key is copied outside of protected memory, although HMAC state derived from
+// them lives on the Go heap while HKDF runs.
+//
+// HKDF returns an error if outLen is more than HKDF can produce for hashFn, and panics if
+// outLen is not positive.
+func (b *Buffer) HKDF(hashFn func() hash.Hash, salt, info []byte, outLen int) (*Buffer, error) {
+	if outLen <= 0 {
+		panic("non-positive outLen requested")
+	}
+
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return nil, err
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+
+	d, err := alloc(outLen, b.inherited())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(hkdf.New(hashFn, b.data[:b.i], salt, info), d.data[:outLen]); err != nil {
+		if e := d.Free(); e != nil {
+			panic(e)
+		}
+		return nil, err
+	}
+	d.setLen(outLen)
+	return d, nil
+}
@@ -0,0 +1,57 @@
+package mlock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestHKDF(t *testing.T) {
+	// RFC 5869, Appendix A.1.
+	ikm := mustHex(t, "0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt := mustHex(t, "000102030405060708090a0b0c")
+	info := mustHex(t, "f0f1f2f3f4f5f6f7f8f9")
+	okm := mustHex(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+
+	b := allocWith(t, ikm)
+	d, err := b.HKDF(sha256.New, salt, info, len(okm))
+	require.NoError(t, err)
+	require.Equal(t, okm, d.View())
+
+	expected := make([]byte, 100)
+	_, err = io.ReadFull(hkdf.New(sha256.New, ikm, nil, []byte("subkey")), expected)
+	require.NoError(t, err)
+
+	d2, err := b.HKDF(sha256.New, nil, []byte("subkey"), len(expected))
+	require.NoError(t, err)
+	require.Equal(t, expected, d2.View())
+
+	// The derived key is protected as its input is.
+	c, err := Alloc(kb, WithChecksum())
+	require.NoError(t, err)
+	_, err = c.Write(ikm)
+	require.NoError(t, err)
+	d3, err := c.HKDF(sha256.New, salt, info, len(okm))
+	require.NoError(t, err)
+	require.Equal(t, c.opts, d3.opts)
+	require.Equal(t, okm, d3.View())
+	require.NoError(t, d3.Verify())
+	freeAll(t, c, d3)
+
+	_, err = b.HKDF(sha256.New, nil, nil, 255*sha256.Size+1)
+	require.Error(t, err)
+
+	freeAll(t, b, d, d2)
+	_, err = b.HKDF(sha256.New, nil, nil, 32)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
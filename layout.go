@@ -0,0 +1,94 @@
+package mlock
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// Region is a contiguous part of a Buffer's mapping, Len bytes long and starting Offset
+// bytes from the start of the mapping.
+type Region struct {
+	Offset int
+	Len    int
+}
+
+// slice returns the part of buf covered by r.
+func (r Region) slice(buf []byte) []byte {
+	return buf[r.Offset : r.Offset+r.Len]
+}
+
+// LayoutDescription describes how a Buffer's memory is laid out. The regions tile the
+// mapping in address order, except that the data and canary swap places, and the padding
+// moves to the end, when the Buffer is allocated WithDataAtPageStart.
+type LayoutDescription struct {
+	Size       int // the length of the whole mapping
+	FrontGuard Region
+	Padding    Region
+	Canary     Region
+	Data       Region
+	RearGuard  Region
+}
+
+// DescribeLayout returns the layout Alloc would produce for a Buffer of the given size
+// and options, without allocating anything. The data region may be larger than bytes if
+// an option rounds up the capacity, as WithMemoryTag does. If the options are invalid, so
+// that Alloc would return an error, the zero LayoutDescription is returned.
+//
+// DescribeLayout panics if bytes is not positive, as Alloc does.
+func DescribeLayout(bytes int, opts ...Option) LayoutDescription {
+	if bytes <= 0 {
+		panic("non-positive bytes requested")
+	}
+
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return LayoutDescription{}
+	}
+	bytes = o.capacity(bytes)
+	return describeLayout(o.requiredBytes(bytes), bytes, &o)
+}
+
+// describeLayout lays out a Buffer with a capacity of n in a mapping of size bytes.
+func describeLayout(size, n int, o *options) LayoutDescription {
+	front, rear := o.guardPages()
+	d := LayoutDescription{
+		Size:       size,
+		FrontGuard: Region{0, front * pagesize},
+		RearGuard:  Region{size - rear*pagesize, rear * pagesize},
+	}
+
+	start, end := d.FrontGuard.Len, d.RearGuard.Offset
+	if o.dataAtPageStart {
+		d.Data = Region{start, n}
+		d.Canary = Region{start + n, CanarySize}
+		d.Padding = Region{start + n + CanarySize, end - start - n - CanarySize}
+		return d
+	}
+
+	d.Data = Region{end - n, n}
+	d.Canary = Region{end - n - CanarySize, CanarySize}
+	d.Padding = Region{start, end - n - CanarySize - start}
+	return d
+}
+
+// shiftAlign is the granularity of the padding moved by WithRandomizedPadding, which
+// keeps the data 16-byte aligned relative to its default position.
+const shiftAlign = 16
+
+// randomShift returns a random multiple of shiftAlign no larger than slack.
+func randomShift(slack int) (int, error) {
+	steps, err := randomUpTo(slack / shiftAlign)
+	return steps * shiftAlign, err
+}
+
+// randomUpTo returns a random integer from 0 to n inclusive.
+func randomUpTo(n int) (int, error) {
+	var r [8]byte
+	if _, err := rand.Read(r[:]); err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint64(r[:]) % uint64(n+1)), nil
+}
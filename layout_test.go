@@ -0,0 +1,114 @@
+package mlock
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeLayout(t *testing.T) {
+	optSets := [][]Option{
+		nil,
+		{WithDataAtPageStart()},
+		{WithGuardPages(2, 3)},
+		{WithGuardPages(3, 1), WithDataAtPageStart()},
+	}
+	for _, opts := range optSets {
+		for _, size := range sizes {
+			d := DescribeLayout(size, opts...)
+			b, err := Alloc(size, opts...)
+			require.NoError(t, err)
+
+			require.Equal(t, len(b.buf), d.Size)
+			require.Equal(t, size, d.Data.Len)
+			for _, r := range []struct {
+				region Region
+				slice  []byte
+			}{
+				{d.FrontGuard, b.frontGuard},
+				{d.Padding, b.padding},
+				{d.Canary, b.canary},
+				{d.Data, b.data},
+				{d.RearGuard, b.rearGuard},
+			} {
+				require.Equal(t, r.region.Len, len(r.slice))
+				if len(r.slice) > 0 {
+					off := uintptr(unsafe.Pointer(&r.slice[0])) - uintptr(unsafe.Pointer(&b.buf[0]))
+					require.Equal(t, r.region.Offset, int(off))
+				}
+			}
+			require.NoError(t, b.Free())
+		}
+	}
+
+	require.Zero(t, DescribeLayout(1, WithGuardPages(0, 1)))
+	require.Panics(t, func() { DescribeLayout(0) })
+}
+
+func TestRandomizedPadding(t *testing.T) {
+	offsets := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		b, err := Alloc(100, WithRandomizedPadding())
+		require.NoError(t, err)
+		b.Strict()
+
+		off := len(b.frontGuard) + len(b.padding) + CanarySize
+		require.Same(t, &b.buf[off], &b.data[0])
+		require.Same(t, &b.buf[off-CanarySize], &b.canary[0])
+		require.Equal(t, len(b.buf)-len(b.rearGuard), off+len(b.data)+len(b.tail))
+		require.Zero(t, len(b.tail)%shiftAlign)
+		offsets[off] = true
+
+		_, err = b.Write(bytes.Repeat([]byte{1}, 100))
+		require.NoError(t, err)
+		require.NoError(t, b.Verify())
+		if len(b.tail) > 0 {
+			b.tail[0] = 1
+			require.EqualError(t, b.Verify(), ErrDataCorrupted.Error())
+			b.tail[0] = 0
+		}
+		require.NoError(t, b.Free())
+	}
+	require.Greater(t, len(offsets), 1)
+
+	_, err := Alloc(100, WithRandomizedPadding(), WithDataAtPageStart())
+	require.EqualError(t, err, ErrConflictingOptions.Error())
+}
+
+func TestCanaryBand(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithDataAtPageStart()}, {WithRandomizedPadding()}} {
+		offsets := make(map[int]bool)
+		for i := 0; i < 20; i++ {
+			b, err := Alloc(100, append(opts, WithCanaryBand(64))...)
+			require.NoError(t, err)
+			b.Strict()
+
+			require.True(t, len(b.gap) <= 64-CanarySize)
+			if b.opts.dataAtPageStart {
+				require.Same(t, &b.data[len(b.data)-1], &b.buf[len(b.frontGuard)+len(b.data)-1])
+				require.Same(t, &b.buf[len(b.frontGuard)+len(b.data)+len(b.gap)], &b.canary[0])
+			} else {
+				off := len(b.buf) - len(b.rearGuard) - len(b.tail) - len(b.data)
+				require.Same(t, &b.buf[off], &b.data[0])
+				require.Same(t, &b.buf[off-len(b.gap)-CanarySize], &b.canary[0])
+			}
+			offsets[len(b.gap)] = true
+
+			_, err = b.Write(bytes.Repeat([]byte{1}, 100))
+			require.NoError(t, err)
+			require.NoError(t, b.Verify())
+			if len(b.gap) > 0 {
+				b.gap[0] = 1
+				require.EqualError(t, b.Verify(), ErrDataCorrupted.Error())
+				b.gap[0] = 0
+			}
+			require.NoError(t, b.Free())
+		}
+		require.Greater(t, len(offsets), 1)
+	}
+
+	require.Equal(t, DescribeLayout(100), DescribeLayout(100, WithCanaryBand(CanarySize)))
+	require.Equal(t, RequiredBytes(4096-CanarySize)+pagesize, DescribeLayout(4096-CanarySize, WithCanaryBand(32)).Size)
+}
@@ -0,0 +1,52 @@
+package mlock
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+// assertNoLeaks returns a function that fails t if any buffer allocated since
+// assertNoLeaks was called has not been freed. It is meant to be deferred:
+//
+//	defer assertNoLeaks(t)()
+func assertNoLeaks(t testing.TB) func() {
+	before := atomic.LoadInt64(&live)
+	return func() {
+		t.Helper()
+		if n := atomic.LoadInt64(&live) - before; n != 0 {
+			t.Errorf("%d buffers leaked", n)
+		}
+	}
+}
+
+// leakRecorder records failures from assertNoLeaks instead of failing the test.
+type leakRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *leakRecorder) Helper() {}
+
+func (r *leakRecorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func TestAssertNoLeaks(t *testing.T) {
+	r := &leakRecorder{TB: t}
+	check := assertNoLeaks(r)
+	b := allocWith(t, text)
+	check()
+	if !r.failed {
+		t.Fatal("leaked buffer not reported")
+	}
+
+	freeAll(t, b)
+	r.failed = false
+	check = assertNoLeaks(r)
+	c := allocWith(t, text)
+	freeAll(t, c)
+	check()
+	if r.failed {
+		t.Fatal("freed buffer reported as leaked")
+	}
+}
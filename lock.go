@@ -0,0 +1,57 @@
+package mlock
+
+import "sync/atomic"
+
+// lockedBytes is the number of bytes locked by Buffers allocated WithLock and not yet
+// freed.
+var lockedBytes int64
+
+// CanLock reports whether a Buffer of the given capacity could be allocated WithLock
+// without exceeding RLIMIT_MEMLOCK, given the memory already locked by this package. It
+// accounts for the Buffer's padding and canary, which are locked along with its data. To
+// check a batch of Buffers, use CanLockAll with each capacity, rather than summing them.
+//
+// Memory locked by other code in the process is not counted, and a process with
+// CAP_IPC_LOCK is not bound by the limit at all, so CanLock is a pre-flight check rather
+// than a guarantee. It returns ErrUnsupported on platforms other than Linux.
+func CanLock(bytes int) (bool, error) {
+	return CanLockAll(bytes)
+}
+
+// CanLockAll is like CanLock, but reports whether Buffers of each of the given capacities
+// could all be allocated WithLock at once, accounting for the padding and canary of each.
+func CanLockAll(sizes ...int) (bool, error) {
+	limit, err := memlockLimit()
+	if err != nil {
+		return false, err
+	}
+	var need uint64
+	for _, bytes := range sizes {
+		need += uint64(RequiredBytes(bytes) - GuardPages*pagesize)
+	}
+	locked := uint64(atomic.LoadInt64(&lockedBytes))
+	return need <= limit && locked <= limit-need, nil
+}
+
+// LockGuarantee probes how much locking memory, with WithLock or MlockAll, can be relied
+// on in this environment, and returns a human-readable report, one finding per line. It
+// covers whether the process is bound by RLIMIT_MEMLOCK and how large it is, whether the
+// kernel supports locking pages on fault (MLOCK_ONFAULT, Linux 4.4), which cgroup version
+// governs the process, and whether swap is enabled at all.
+//
+// The report is meant for operators, and its format may change; it should not be parsed.
+// LockGuarantee returns ErrUnsupported on platforms other than Linux.
+func LockGuarantee() (string, error) {
+	return lockGuarantee()
+}
+
+// memlock locks the memory between b's guard pages.
+func (b *Buffer) memlock() error {
+	region := b.buf[len(b.frontGuard) : len(b.buf)-len(b.rearGuard)]
+	if err := mlock(region, b.opts.lockOnFault); err != nil {
+		return err
+	}
+	b.locked = len(region)
+	atomic.AddInt64(&lockedBytes, int64(b.locked))
+	return nil
+}
@@ -0,0 +1,138 @@
+package mlock
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// mlockOnFault is MLOCK_ONFAULT, the flag to mlock2 to lock pages only once they are
+// faulted in.
+const mlockOnFault = 1
+
+// capIPCLock is the bit for CAP_IPC_LOCK in the capability sets in /proc/self/status.
+const capIPCLock = 14
+
+func memlockLimit() (uint64, error) {
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &rlim); err != nil {
+		return 0, err
+	}
+	return rlim.Cur, nil
+}
+
+// mlock locks b, only as it is faulted in if onFault is set and the kernel supports it.
+func mlock(b []byte, onFault bool) error {
+	if onFault {
+		switch err := mlock2(b, mlockOnFault); err {
+		case syscall.ENOSYS, syscall.EINVAL:
+			// Before Linux 4.4; lock eagerly instead.
+		default:
+			return err
+		}
+	}
+	return syscall.Mlock(b)
+}
+
+func mlock2(b []byte, flags uintptr) error {
+	_, _, errno := syscall.Syscall(unix.SYS_MLOCK2, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), flags)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func lockGuarantee() (string, error) {
+	var report strings.Builder
+
+	limit, err := memlockLimit()
+	if err != nil {
+		return "", err
+	}
+	capable, err := hasCapability(capIPCLock)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case capable:
+		report.WriteString("limit: CAP_IPC_LOCK held, RLIMIT_MEMLOCK does not apply\n")
+	case limit == unix.RLIM_INFINITY:
+		report.WriteString("limit: RLIMIT_MEMLOCK is unlimited\n")
+	default:
+		fmt.Fprintf(&report, "limit: RLIMIT_MEMLOCK is %d bytes, %d already locked by this package\n",
+			limit, atomic.LoadInt64(&lockedBytes))
+	}
+
+	switch err := probeOnFault(); err {
+	case nil:
+		report.WriteString("onfault: MLOCK_ONFAULT supported\n")
+	case syscall.ENOSYS, syscall.EINVAL:
+		report.WriteString("onfault: MLOCK_ONFAULT unsupported, locking populates every page\n")
+	default:
+		fmt.Fprintf(&report, "onfault: MLOCK_ONFAULT unknown, probe failed: %v\n", err)
+	}
+
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		report.WriteString("cgroup: v2, locked pages are charged to the cgroup's memory.max\n")
+	} else {
+		report.WriteString("cgroup: v1 or none\n")
+	}
+
+	swaps, err := os.ReadFile("/proc/swaps")
+	if err != nil {
+		return "", err
+	}
+	// The first line is a header.
+	switch n := bytes.Count(swaps, []byte("\n")) - 1; {
+	case n <= 0:
+		report.WriteString("swap: none, unlocked memory cannot be swapped either\n")
+	default:
+		fmt.Fprintf(&report, "swap: %d active, unlocked memory can be swapped out\n", n)
+	}
+
+	report.WriteString("note: locked memory is still written out by hibernation, and copied into core dumps\n")
+	return report.String(), nil
+}
+
+// probeOnFault tries locking a scratch page with MLOCK_ONFAULT.
+func probeOnFault() error {
+	page, err := syscall.Mmap(-1, 0, pagesize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return err
+	}
+	defer syscall.Munmap(page)
+
+	if err := mlock2(page, mlockOnFault); err != nil {
+		return err
+	}
+	return syscall.Munlock(page)
+}
+
+// hasCapability reports whether the process has capability bit c in its effective set.
+func hasCapability(c uint) (bool, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "CapEff:"); ok {
+			caps, err := strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+			if err != nil {
+				return false, err
+			}
+			return caps&(1<<c) != 0, nil
+		}
+	}
+	return false, s.Err()
+}
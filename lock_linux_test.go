@@ -0,0 +1,184 @@
+package mlock
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestCanLock(t *testing.T) {
+	var orig unix.Rlimit
+	require.NoError(t, unix.Getrlimit(unix.RLIMIT_MEMLOCK, &orig))
+	defer func() { require.NoError(t, unix.Setrlimit(unix.RLIMIT_MEMLOCK, &orig)) }()
+
+	// Leave room for exactly two pages beyond what is already locked.
+	lim := orig
+	lim.Cur = uint64(lockedBytes) + 2*uint64(pagesize)
+	require.NoError(t, unix.Setrlimit(unix.RLIMIT_MEMLOCK, &lim))
+
+	ok, err := CanLock(2*pagesize - CanarySize)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = CanLock(2 * pagesize)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Each Buffer in a batch needs its own canary and page rounding.
+	ok, err = CanLockAll(pagesize-CanarySize, pagesize-CanarySize)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = CanLockAll(kb, kb, kb)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	b, err := Alloc(kb, WithLock())
+	if err != nil {
+		t.Skipf("cannot lock memory: %v", err)
+	}
+	require.Equal(t, pagesize, b.locked)
+
+	ok, err = CanLock(kb)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = CanLock(pagesize)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Decommit cannot release locked pages, so zeroes them instead.
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.NoError(t, b.Decommit())
+	require.Equal(t, make([]byte, kb), b.ViewCap())
+
+	require.NoError(t, b.Free())
+	ok, err = CanLock(pagesize)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestMlockAll(t *testing.T) {
+	if err := MlockAll(); err != nil {
+		t.Skipf("cannot lock process memory: %v", err)
+	}
+	defer func() { require.NoError(t, syscall.Munlockall()) }()
+
+	b, err := Alloc(4 * pagesize)
+	require.NoError(t, err)
+	ok, err := b.Resident()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, b.Free())
+}
+
+func TestLockGuarantee(t *testing.T) {
+	report, err := LockGuarantee()
+	require.NoError(t, err)
+	require.NotEmpty(t, report)
+	for _, finding := range []string{"limit: ", "onfault: ", "cgroup: ", "swap: "} {
+		require.Contains(t, "\n"+report, "\n"+finding)
+	}
+}
+
+// lockedKB returns the Locked figure from /proc/self/smaps for the mapping containing p.
+func lockedKB(t *testing.T, p *byte) int {
+	smaps, err := os.ReadFile("/proc/self/smaps")
+	require.NoError(t, err)
+
+	addr := uint64(uintptr(unsafe.Pointer(p)))
+	in := false
+	for _, line := range strings.Split(string(smaps), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if bounds := strings.SplitN(fields[0], "-", 2); len(bounds) == 2 && !strings.HasSuffix(fields[0], ":") {
+			start, err1 := strconv.ParseUint(bounds[0], 16, 64)
+			end, err2 := strconv.ParseUint(bounds[1], 16, 64)
+			if err1 == nil && err2 == nil {
+				in = start <= addr && addr < end
+				continue
+			}
+		}
+		if in && fields[0] == "Locked:" {
+			kb, err := strconv.Atoi(fields[1])
+			require.NoError(t, err)
+			return kb
+		}
+	}
+	t.Fatal("mapping not found in smaps")
+	return 0
+}
+
+func TestLockOnFault(t *testing.T) {
+	size := 64 * pagesize
+	eager, err := Alloc(size, WithLock())
+	if err != nil {
+		t.Skipf("cannot lock memory: %v", err)
+	}
+	defer freeAll(t, eager)
+	require.Equal(t, (size+pagesize)/kb, lockedKB(t, &eager.data[0]))
+
+	b, err := Alloc(size, WithLockOnFault())
+	require.NoError(t, err)
+	defer freeAll(t, b)
+	require.Equal(t, size+pagesize, b.locked)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	// Only the pages holding the canary and the written data have been touched.
+	locked := lockedKB(t, &b.data[0])
+	require.Less(t, locked, size/kb)
+	require.GreaterOrEqual(t, locked, pagesize/kb)
+}
+
+func TestAllocReady(t *testing.T) {
+	size := 64 * kb
+	b, err := AllocReady(size)
+	require.NoError(t, err)
+
+	ok, err := b.Resident()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, len(b.buf)-GuardPages*pagesize, b.locked)
+	require.Equal(t, b.locked/kb, lockedKB(t, &b.data[0]))
+
+	require.NoError(t, b.Free())
+}
+
+func TestDecommitLockedPages(t *testing.T) {
+	size := 8 * pagesize
+	b, err := Alloc(size)
+	require.NoError(t, err)
+	_, err = b.Write(bytes.Repeat([]byte{1}, size))
+	require.NoError(t, err)
+
+	// Lock the pages behind b's back, as MlockAll does, so they cannot be released.
+	require.NoError(t, syscall.Mlock(b.data))
+
+	require.NoError(t, b.Decommit())
+	require.Zero(t, b.Len())
+	require.Equal(t, make([]byte, size), b.data)
+	require.NoError(t, syscall.Munlock(b.data))
+	require.NoError(t, b.Free())
+}
+
+func TestRestoreLocked(t *testing.T) {
+	aead := newTestAEAD(t)
+	b := allocWith(t, text)
+	snapshot, err := b.Snapshot(aead)
+	require.NoError(t, err)
+	freeAll(t, b)
+
+	r, err := Restore(aead, snapshot, WithLock())
+	require.NoError(t, err)
+	require.Equal(t, text, r.View())
+	require.Equal(t, pagesize, r.locked)
+	freeAll(t, r)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package mlock
+
+func memlockLimit() (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+func mlock(b []byte, onFault bool) error {
+	return ErrUnsupported
+}
+
+func lockGuarantee() (string, error) {
+	return "", ErrUnsupported
+}
@@ -0,0 +1,34 @@
+package mlock
+
+import "sync/atomic"
+
+// Events passed to the function set by SetLogger.
+const (
+	// EventAlloc is logged when a Buffer is allocated.
+	EventAlloc = "alloc"
+
+	// EventFree is logged when a Buffer is freed, including on expiry.
+	EventFree = "free"
+
+	// EventCorruption is logged whenever an integrity check finds a Buffer corrupt.
+	EventCorruption = "corruption"
+)
+
+var logger atomic.Value // func(event string, b *Buffer)
+
+// SetLogger sets a function to be called on Buffer lifecycle events, such as EventAlloc,
+// with the event and the Buffer concerned. The function may inspect the Buffer's metadata,
+// such as its Len or Cap, but must never read or log its data. Passing nil removes the
+// logger, which is the default.
+//
+// The logger is called synchronously from the method that caused the event, so it runs on
+// the hot path and should be cheap.
+func SetLogger(fn func(event string, b *Buffer)) {
+	logger.Store(fn)
+}
+
+func logEvent(event string, b *Buffer) {
+	if fn, _ := logger.Load().(func(string, *Buffer)); fn != nil {
+		fn(event, b)
+	}
+}
@@ -0,0 +1,45 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type loggedEvent struct {
+	event string
+	len   int
+}
+
+func TestSetLogger(t *testing.T) {
+	var events []loggedEvent
+	SetLogger(func(event string, b *Buffer) {
+		events = append(events, loggedEvent{event, b.Len()})
+	})
+	defer SetLogger(nil)
+
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	b.canary[0]++
+	require.Nil(t, b.View())
+	b.canary[0]--
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	require.Equal(t, []loggedEvent{
+		{EventAlloc, 0},
+		{EventCorruption, len(text)},
+		{EventFree, 0},
+	}, events)
+
+	events = nil
+	SetLogger(nil)
+	b, err = Alloc(len(text))
+	require.NoError(t, err)
+	require.NoError(t, b.Free())
+	require.Empty(t, events)
+}
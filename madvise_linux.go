@@ -0,0 +1,34 @@
+package mlock
+
+import "syscall"
+
+// madvWipeOnFork and madvDontDump are MADV_WIPEONFORK and MADV_DONTDUMP, which the
+// syscall package does not define.
+const (
+	madvWipeOnFork = 0x12
+	madvDontDump   = 0x10
+)
+
+func advise(buf []byte, o *options) error {
+	if o.noFork {
+		if err := syscall.Madvise(buf, syscall.MADV_DONTFORK); err != nil {
+			return err
+		}
+	}
+	if o.wipeOnFork {
+		if err := syscall.Madvise(buf, madvWipeOnFork); err != nil {
+			return err
+		}
+	}
+	if o.noDump {
+		if err := syscall.Madvise(buf, madvDontDump); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropPages releases pages back to the system. They read as zero when next touched.
+func dropPages(pages []byte) error {
+	return syscall.Madvise(pages, syscall.MADV_DONTNEED)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package mlock
+
+func advise(buf []byte, o *options) error {
+	if o.noFork || o.wipeOnFork || o.noDump {
+		return ErrUnsupported
+	}
+	return nil
+}
+
+// dropPages zeroes pages, since MADV_DONTNEED does not guarantee zero-filled pages on
+// platforms other than Linux.
+func dropPages(pages []byte) error {
+	wipe(pages)
+	return nil
+}
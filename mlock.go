@@ -3,9 +3,15 @@ package mlock
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/binary"
 	"errors"
+	"hash/crc32"
 	"io"
-	"syscall"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -19,21 +25,58 @@ const (
 var (
 	canary   [CanarySize]byte // initialized at startup
 	pagesize int
+	nextID   uint64
+	live     int64 // buffers mapped and not yet freed
 )
 
 // Buffer is a securely mlock-ed buffer allocated outside the Go runtime.
+//
+// A Buffer's methods synchronize with the package's own background work on it, such as
+// expiry, but a Buffer should not otherwise be shared between goroutines without
+// additional synchronization.
 type Buffer struct {
-	buf []byte // original buffer, for un-mapping
+	mu sync.Mutex // held for the duration of every exported method
+	id uint64     // allocation order, for locking several buffers at once
+
+	buf []byte   // original buffer, for un-mapping
+	p   Provider // provider that mapped buf
 
 	frontGuard []byte
 	padding    []byte
 	canary     []byte
+	gap        []byte // zeros between the canary and data, if allocated WithCanaryBand
 	data       []byte
+	tail       []byte // padding after the data, if allocated WithRandomizedPadding
 	rearGuard  []byte
 
 	i int
 
-	strict bool // check padding as well as canary on access
+	opts      options
+	strict    bool        // check padding as well as canary on access
+	ttl       *time.Timer // expires the buffer, if allocated with WithTTL
+	deadline  time.Time   // when ttl fires
+	verifier  *time.Timer // verifies the buffer, if allocated WithPeriodicVerify
+	committed int         // end of the accessible region, if allocated by AllocReserved
+	shift     int         // padding moved after the data, if allocated WithRandomizedPadding
+	gapLen    int         // length of gap, if allocated WithCanaryBand
+	expired   bool
+	finalized bool   // see Finalize
+	sum       uint32 // CRC-32C of the written data, if allocated with WithChecksum
+	gen       uint64 // incremented by every change to the data
+
+	allocLatency time.Duration // time taken to allocate, if allocated WithLatencyTracking
+	label        string        // set by SetLabel
+
+	guardsChecked time.Time // last guard probe, if allocated with WithGuardVerify
+
+	sealer *sealer // encrypts the data, if allocated WithEncryptedAtRest
+
+	tag    byte // MTE allocation tag of the data, or zero if untagged
+	locked int  // bytes locked, if allocated WithLock
+	shared bool // backed by a memfd, if allocated by NewSharedBuffer
+
+	event string // logged once the buffer is unlocked
+	warn  bool   // overflow warning, delivered once the buffer is unlocked
 }
 
 // Alloc allocations a Buffer with the requested number of bytes. The bytes passed should
@@ -44,17 +87,48 @@ type Buffer struct {
 // with it. Failing to do so will leak the memory, and if the Buffer goes out of scope
 // without being freed, there is no way to release the memory until the process exits.
 //
-// Alloc panics if bytes is not positive.
-func Alloc(bytes int) (b *Buffer, err error) {
+// Options may be passed to further configure the Buffer's memory. Alloc returns
+// ErrConflictingOptions if the options are mutually exclusive.
+//
+// Alloc panics if bytes is not positive. Unlike an out of range index, which may come
+// from computation on untrusted input, the size of an allocation is always chosen by the
+// caller, so a non-positive size is a programming error.
+func Alloc(bytes int, opts ...Option) (b *Buffer, err error) {
 	if bytes <= 0 {
 		panic("non-positive bytes requested")
 	}
 
-	needed := RequiredBytes(bytes)
-	buf, err := syscall.Mmap(-1, 0, needed, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return alloc(bytes, o)
+}
+
+// alloc allocates a Buffer of the given size with validated options.
+func alloc(bytes int, o options) (*Buffer, error) {
+	var start time.Time
+	if o.latencyTracking {
+		start = time.Now()
+	}
+
+	bytes = o.capacity(bytes)
+	p := provider
+	needed := o.requiredBytes(bytes)
+	buf, err := mmap(p, needed)
 	if err != nil {
 		return nil, err
 	}
+	return newBuffer(p, buf, bytes, o, start)
+}
+
+// newBuffer lays out a Buffer with the given capacity over buf, a mapping of
+// o.requiredBytes(bytes) made by p, and protects its guard pages. If it fails, buf is
+// unmapped. start is when the allocation began, for WithLatencyTracking.
+func newBuffer(p Provider, buf []byte, bytes int, o options, start time.Time) (b *Buffer, err error) {
 	defer func() {
 		if err == nil {
 			return
@@ -65,53 +139,145 @@ func Alloc(bytes int) (b *Buffer, err error) {
 		b = nil
 	}()
 
-	// starting indices of sub-buffers, reverse order
-	ri := len(buf) - pagesize
-	di := ri - bytes
-	ci := di - CanarySize
-	pi := pagesize
-	fi := 0
-
 	b = &Buffer{
-		buf:        buf,
-		frontGuard: buf[fi:pi], // fi not needed, here for clarity
-		padding:    buf[pi:ci],
-		canary:     buf[ci:di],
-		data:       buf[di:ri],
-		rearGuard:  buf[ri:],
+		id:   atomic.AddUint64(&nextID, 1),
+		opts: o,
+		buf:  buf,
+		p:    p,
+	}
+	if o.reserve > 0 {
+		front, _ := o.guardPages()
+		b.committed = roundPage(front*pagesize + bytes + CanarySize)
+	}
+	if slack := o.bandSlack(); slack > 0 {
+		if b.gapLen, err = randomUpTo(slack); err != nil {
+			return b, err
+		}
+	}
+	if o.randomPadding {
+		if b.shift, err = randomShift(describeLayout(len(buf), bytes, &o).Padding.Len); err != nil {
+			return b, err
+		}
+	}
+	b.layout(bytes)
+	// A Provider may hand out reused memory, so zero the padding rather than relying on
+	// a fresh anonymous mapping for strict mode to pass.
+	wipe(b.padding)
+	wipe(b.gap)
+	wipe(b.tail)
+	atomic.AddInt64(&live, 1)
+	track(b)
+
+	if err = mprotect(p, b.frontGuard, protNone); err != nil {
+		return b, err
 	}
 
-	if err = syscall.Mprotect(b.frontGuard, syscall.PROT_NONE); err != nil {
+	if err = mprotect(p, b.rearGuard, protNone); err != nil {
 		return b, err
 	}
 
-	if err = syscall.Mprotect(b.rearGuard, syscall.PROT_NONE); err != nil {
+	if err = advise(b.buf, &o); err != nil {
 		return b, err
 	}
 
+	if o.lock {
+		if err = b.memlock(); err != nil {
+			return b, err
+		}
+	}
+
+	if o.bindNode {
+		if err = bindNode(b.pages(b.data), o.numaNode); err != nil {
+			return b, err
+		}
+	}
+
+	if o.memoryTag {
+		if err = tagMemory(b); err != nil {
+			return b, err
+		}
+	}
+
+	if o.prefaultWorkers > 0 {
+		prefault(b.data, o.prefaultWorkers)
+	}
+
+	if o.encryptedAtRest {
+		if b.sealer, err = newSealer(p); err != nil {
+			return b, err
+		}
+	}
+
 	if n := copy(b.canary, canary[:]); n != CanarySize {
 		panic("copied wrong number of bytes to canary")
 	}
 
+	// The timers' functions lock b, so holding the lock keeps them from running until
+	// both timers are assigned.
+	b.mu.Lock()
+	if o.ttl > 0 {
+		b.deadline = time.Now().Add(o.ttl)
+		b.ttl = time.AfterFunc(o.ttl, b.expire)
+	}
+
+	if o.verifyEvery > 0 {
+		b.verifier = time.AfterFunc(o.verifyEvery, b.periodicVerify)
+	}
+	b.mu.Unlock()
+
+	if o.latencyTracking {
+		b.allocLatency = time.Since(start)
+	}
+	logEvent(EventAlloc, b)
 	return b, nil
 }
 
 // Realloc allocates a buffer with the new size, copies the contents of b into it, and
 // then calls b.Free(). The new size must be able to hold the contents of b.
 //
+// If size needs the same number of pages as b's current capacity and no options are
+// given, b is resized within its existing mapping and returned itself, rather than freed.
+//
+// Otherwise, the new buffer is allocated with the options b was allocated with, followed
+// by opts, which may override them, and is in strict mode if b is. A TTL carries over as
+// the time remaining on b's, so reallocating does not extend a secret's lifetime.
+//
 // Realloc panics if size is not positive.
-func (b *Buffer) Realloc(size int) (r *Buffer, err error) {
+func (b *Buffer) Realloc(size int, opts ...Option) (r *Buffer, err error) {
 	if size <= 0 {
 		panic("non-positive size requested")
 	}
-	if err := b.canaryCheck(); err != nil {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.realloc(size, opts...)
+}
+
+func (b *Buffer) realloc(size int, opts ...Option) (r *Buffer, err error) {
+	if err := b.writableCheck(); err != nil {
+		return nil, err
+	}
+
+	if len(opts) == 0 && b.tag == 0 && b.committed == 0 && b.opts.requiredBytes(size) == len(b.buf) {
+		if size < b.i {
+			return nil, ErrBufferTooSmall
+		}
+		b.move(size)
+		return b, nil
+	}
+
+	o := b.inherited()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
 		return nil, err
 	}
 
-	r, err = Alloc(size)
+	r, err = alloc(size, o)
 	if err != nil {
 		return nil, err
 	}
+	r.strict, r.label = b.strict, b.label
 	defer func() {
 		if err == nil {
 			return
@@ -122,14 +288,53 @@ func (b *Buffer) Realloc(size int) (r *Buffer, err error) {
 		r = nil
 	}()
 
-	if _, err := r.Write(b.data[:b.i]); err != nil {
+	b.unseal(b.i)
+	_, err = r.Write(b.data[:b.i])
+	b.seal(b.i)
+	if err != nil {
 		if err == ErrBufferFull {
 			return r, ErrBufferTooSmall
 		}
 		return r, err
 	}
 
-	return r, b.Free()
+	return r, b.free()
+}
+
+// Reuse recycles the buffer for new data of up to size bytes, as a pool would. Its data
+// is wiped either way. If size fits in b's mapping, b's capacity is set to size in place,
+// and b itself is returned. Otherwise, Reuse behaves like Realloc(size) on the emptied
+// buffer, returning a new Buffer and freeing b.
+//
+// Reuse panics if size is not positive.
+func (b *Buffer) Reuse(size int) (*Buffer, error) {
+	if size <= 0 {
+		panic("non-positive size requested")
+	}
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return nil, err
+	}
+
+	if size <= b.physCap() {
+		b.resize(size)
+		return b, nil
+	}
+	b.zero()
+	return b.realloc(size)
+}
+
+// inherited returns the options for a Buffer taking over b's data: b's own, with a TTL
+// of the time remaining on b's, so that moving a secret does not extend its lifetime.
+func (b *Buffer) inherited() options {
+	o := b.opts
+	if o.ttl > 0 {
+		// At least 1ns, since a non-positive TTL would disable expiry.
+		o.ttl = max(time.Until(b.deadline), 1)
+	}
+	o.reserve = 0
+	return o
 }
 
 // View returns a view on the written user data for the buffer. It may be written to or
@@ -140,61 +345,214 @@ func (b *Buffer) Realloc(size int) (r *Buffer, err error) {
 // point). Calling cap(b.View()) will return a value that is not useful to the caller,
 // use b.Cap() instead.
 //
-// If b is corrupt or freed, a nil buffer is returned.
+// If b is corrupt or freed, or was allocated WithEncryptedAtRest, a nil buffer is
+// returned. This is indistinguishable from an empty buffer, so a caller that does not
+// check for nil may silently use no data at all in place of corrupt data. Prefer ViewErr,
+// which reports why the view is unavailable.
 func (b *Buffer) View() []byte {
+	v, _ := b.ViewErr()
+	return v
+}
+
+// ViewN returns the same view as View, but no longer than n bytes, for passing the data
+// to something that should receive at most n bytes of it. A negative n is treated as zero.
+// Like View, ViewN returns nil if b is corrupt or freed.
+func (b *Buffer) ViewN(n int) []byte {
+	v := b.View()
+	if v == nil {
+		return nil
+	}
+	return v[:min(max(n, 0), len(v))]
+}
+
+// ViewErr returns the same view on the written user data as View, but returns an error
+// rather than a nil buffer if b is corrupt or freed. A Buffer allocated
+// WithEncryptedAtRest only holds its data decrypted while it is locked, so it has no view
+// to return, and ViewErr returns ErrConflictingOptions; use WithView instead.
+func (b *Buffer) ViewErr() ([]byte, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return nil, err
+	}
+	if b.sealer != nil {
+		return nil, ErrConflictingOptions
+	}
+
+	return b.data[:b.i], nil
+}
+
+// ViewCap returns a view on the buffer's entire data section, up to its capacity, for
+// use as the destination of in-place operations such as decryption. Only the first
+// b.Len() bytes are considered written: writing to the rest of the view does not advance
+// the write index, so the caller must do so afterwards, e.g. with b.Seek. The same
+// restrictions on copying the data apply as for View.
+//
+// For a Buffer allocated WithEncryptedAtRest, the written part of the view holds
+// ciphertext, so the view is only useful for appending data after it, which SetLen then
+// encrypts.
+//
+// If b is corrupt or freed, a nil buffer is returned.
+func (b *Buffer) ViewCap() []byte {
+	b.mu.Lock()
+	defer b.unlock()
 	if err := b.canaryCheck(); err != nil {
 		return nil
 	}
 
-	return b.data[:b.i]
+	return b.data[:len(b.data):len(b.data)]
 }
 
-// Cap returns the capacity of the buffer. The length is accessible via the buffer
-// returned by b.View().
+// Generation returns the buffer's generation, which changes whenever its data is changed
+// through one of its methods. Changes made directly through a view are not counted. See
+// EqualFresh.
+func (b *Buffer) Generation() uint64 {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.gen
+}
+
+// Cap returns the capacity of the buffer. The length is accessible via b.Len().
+//
+// This is the buffer's logical capacity. Its mapping may have room for more data, if it
+// was allocated for a larger size and then resized for reuse, but only Cap bytes are ever
+// accessible.
 func (b *Buffer) Cap() int {
+	b.mu.Lock()
+	defer b.unlock()
 	return len(b.data)
 }
 
-// Seek sets the current write index in the buffer. Seek panics if the index is negative.
-// It is an error to seek past the capacity of the buffer.
+// AllocLatency returns how long the buffer took to allocate, from the start of the mmap
+// through protecting its guard pages, locking and prefaulting it, and any other setup its
+// options call for. It is only recorded for a buffer allocated WithLatencyTracking, and
+// is zero otherwise.
+func (b *Buffer) AllocLatency() time.Duration {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.allocLatency
+}
+
+// Len returns the number of bytes of user data written to the buffer. It does not check
+// the buffer's integrity, and never exposes the data itself.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.i
+}
+
+// Seek sets the current write index in the buffer. It is an error to seek to a negative
+// index or past the capacity of the buffer.
 func (b *Buffer) Seek(i int) error {
-	if i < 0 {
-		panic("negative index")
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
 	}
-	if err := b.canaryCheck(); err != nil {
+
+	if i < 0 || i >= len(b.data) {
+		return ErrSeekOutOfBounds
+	}
+	b.setLen(i)
+	return nil
+}
+
+// SetLen sets the length of the buffer's written data to n, for use after the data has
+// been written directly into the view returned by ViewCap. Unlike Seek, n may be equal to
+// the capacity of the buffer. It is an error for n to be negative or exceed the capacity.
+func (b *Buffer) SetLen(n int) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	if n < 0 || n > len(b.data) {
+		return ErrSeekOutOfBounds
+	}
+	b.setLen(n)
+	return nil
+}
+
+// Rewind zeroes all of the buffer's data after the first keep bytes, and sets the write
+// index to keep, so that subsequent writes replace the wiped suffix. It is an error to
+// rewind to a negative index or past the current length of the buffer.
+func (b *Buffer) Rewind(keep int) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
 		return err
 	}
 
-	if i >= b.Cap() {
+	if keep < 0 || keep > b.i {
 		return ErrSeekOutOfBounds
 	}
-	b.i = i
+	wipe(b.data[keep:])
+	b.setLen(keep)
 	return nil
 }
 
 var _ io.Writer = (*Buffer)(nil)
 
-// Write implements the io.Writer interface.
+// Write implements the io.Writer interface. If buf does not fit in the buffer, Write
+// writes as much of it as fits and returns ErrBufferFull, rather than io.ErrShortWrite.
+// Since the error is non-nil, io.Copy and bufio.Writer return it as is.
 func (b *Buffer) Write(buf []byte) (int, error) {
-	if err := b.canaryCheck(); err != nil {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.write(buf)
+}
+
+func (b *Buffer) write(buf []byte) (int, error) {
+	if err := b.writableCheck(); err != nil {
 		return 0, err
 	}
 
 	n := copy(b.data[b.i:], buf)
-	b.i += n
+	b.advance(n)
 	if n < len(buf) {
 		return n, ErrBufferFull
 	}
 	return n, nil
 }
 
+// advance moves the write index past n bytes just written at it, updating the checksum
+// and generation.
+func (b *Buffer) advance(n int) {
+	b.gen++
+	if n > 0 && b.opts.overflowWarn != nil && b.i+n > len(b.data)-CanarySize {
+		b.warn = true
+	}
+	b.sealRange(b.i, b.i+n)
+	if b.opts.checksum {
+		b.sum = crc32.Update(b.sum, castagnoli, b.data[b.i:b.i+n])
+	}
+	b.i += n
+}
+
+// setLen moves the write index to n, recomputing the checksum and updating the
+// generation. Every change to the data made by the Buffer's methods goes through advance
+// or setLen.
+func (b *Buffer) setLen(n int) {
+	b.gen++
+	b.sealRange(b.i, n)
+	b.i = n
+	if b.opts.checksum {
+		b.sum = crc32.Checksum(b.data[:n], castagnoli)
+	}
+}
+
 const progressThresh = 10
 
 var _ io.ReaderFrom = (*Buffer)(nil)
 
-// ReadFrom implements the io.ReadFrom interface.
+// ReadFrom implements the io.ReadFrom interface, and is used by io.Copy when copying into
+// the buffer. If r has more data than fits in the buffer, ReadFrom fills the buffer and
+// returns ErrBufferFull.
 func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
-	if err := b.canaryCheck(); err != nil {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
 		return 0, err
 	}
 
@@ -202,7 +560,7 @@ func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
 	var total int64
 	for {
 		n, err := r.Read(b.data[b.i:])
-		b.i += n
+		b.advance(n)
 		total += int64(n)
 
 		switch n {
@@ -214,6 +572,9 @@ func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
 
 		switch {
 		case err == nil:
+			if b.i == len(b.data) {
+				return total, probeFull(r)
+			}
 			if zeros > progressThresh {
 				return total, io.ErrNoProgress
 			}
@@ -226,6 +587,26 @@ func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
 	}
 }
 
+// probeFull reads from r once the buffer is full, to tell a reader that exactly filled
+// it from one with more data. It returns nil if r is at EOF, and ErrBufferFull if it has
+// more data. The probed byte is not part of the buffer, so it is wiped and discarded.
+func probeFull(r io.Reader) error {
+	var probe [1]byte
+	for zeros := 0; zeros <= progressThresh; zeros++ {
+		n, err := r.Read(probe[:])
+		probe[0] = 0
+		switch {
+		case n > 0:
+			return ErrBufferFull
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
 var (
 	// ErrAlreadyFreed means that the buffer has already freed.
 	ErrAlreadyFreed = errors.New("buffer already free-d")
@@ -242,63 +623,473 @@ var (
 	// ErrBufferTooSmall means that the Buffer requested by a call to Realloc was too
 	// small to hold the original Buffer's data.
 	ErrBufferTooSmall = errors.New("realloc-ed buffer too small")
+
+	// ErrConflictingOptions means that mutually exclusive options were passed to Alloc,
+	// or that a method cannot be used with the options the buffer was allocated with.
+	ErrConflictingOptions = errors.New("conflicting options")
+
+	// ErrUnsupported means that the requested feature is not supported on this platform.
+	ErrUnsupported = errors.New("not supported on this platform")
+
+	// ErrLengthOverflow means that the buffer's length does not fit in a length prefix.
+	ErrLengthOverflow = errors.New("length overflows length prefix")
+
+	// ErrLengthPrefix means that a length prefix read by AllocFromPrefixed exceeded the
+	// maximum length.
+	ErrLengthPrefix = errors.New("length prefix too large")
+
+	// ErrGuardAccessible means that a guard page could be accessed without faulting.
+	ErrGuardAccessible = errors.New("guard page accessible")
+
+	// ErrLengthMismatch means that buffers which must have equal lengths did not.
+	ErrLengthMismatch = errors.New("buffer lengths differ")
+
+	// ErrExpired means that the buffer's TTL has passed, and its data has been wiped.
+	ErrExpired = errors.New("buffer expired")
+
+	// ErrUnaligned means that the buffer's free space is not page aligned, as direct I/O
+	// requires.
+	ErrUnaligned = errors.New("buffer not page aligned")
+
+	// ErrStale means that the buffer's data has changed since its generation was read.
+	ErrStale = errors.New("buffer changed since generation was read")
+
+	// ErrFinalized means that the buffer has been finalized, and its data can no longer be
+	// changed.
+	ErrFinalized = errors.New("buffer finalized")
+
+	// ErrInvariantViolated means that a check enabled by WithAssertions found the buffer's
+	// internal state inconsistent, which indicates a bug in this package.
+	ErrInvariantViolated = errors.New("buffer invariant violated")
+
+	// ErrGuardPages means that WithGuardPages was passed fewer than one page for a guard.
+	ErrGuardPages = errors.New("guard page count must be at least 1")
+
+	// ErrInvalidSnapshot means that a snapshot passed to Restore is malformed.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+	// ErrInvalidCiphertext means that a file passed to DecryptFileInto cannot be split
+	// into valid frames.
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+	// ErrEnvNotSet means that the environment variable passed to FromEnv is not set.
+	ErrEnvNotSet = errors.New("environment variable not set")
+
+	// ErrBlockSize means that the buffer's length is not a multiple of a cipher's block
+	// size.
+	ErrBlockSize = errors.New("length not a multiple of the block size")
+
+	// ErrNotReserved means that Grow was called on a buffer not allocated by
+	// AllocReserved.
+	ErrNotReserved = errors.New("buffer has no reservation")
 )
 
-// Free releases the buffer back to the system.
+// Free releases the buffer back to the system. If the buffer was already freed, Free
+// returns ErrAlreadyFreed, or ErrExpired if it was freed on expiry, unless it was
+// allocated WithIdempotentFree.
 func (b *Buffer) Free() error {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.free()
+}
+
+func (b *Buffer) free() error {
 	if b.buf == nil {
+		switch {
+		case b.opts.idempotentFree:
+			return nil
+		case b.expired:
+			return ErrExpired
+		}
 		return ErrAlreadyFreed
 	}
-	b.Zero()
-	if err := syscall.Munmap(b.buf); err != nil {
+	if b.ttl != nil {
+		b.ttl.Stop()
+	}
+	if b.verifier != nil {
+		b.verifier.Stop()
+	}
+	if b.opts.insecure {
+		b.setLen(0)
+	} else {
+		if b.finalized {
+			// The pages are about to be unmapped, so leave them writable for the poison.
+			if err := mprotect(b.p, b.pages(b.data), protReadWrite); err != nil {
+				return err
+			}
+		}
+		b.zero()
+		if v := byte(atomic.LoadUint32(&freePoison)); v != 0 {
+			fill(b.data, v)
+		}
+	}
+	if err := munmap(b.p, b.buf); err != nil {
 		return err
 	}
 	b.buf = nil
+	if b.sealer != nil {
+		b.sealer.free()
+		b.sealer = nil
+	}
+	atomic.AddInt64(&live, -1)
+	atomic.AddInt64(&lockedBytes, -int64(b.locked))
+	untrack(b)
+	b.event = EventFree
 	return nil
 }
 
 // Zero sets the data section of the buffer to all zeros, and resets the write location
-// to the start of the buffer.
+// to the start of the buffer. A finalized buffer's pages must be made writable to wipe
+// it, which can fail; use ZeroErr to learn if it did.
 func (b *Buffer) Zero() {
-	b.data[0] = 0
+	_ = b.ZeroErr()
+}
+
+// ZeroErr is like Zero, but returns an error if b is finalized and the protection of its
+// pages cannot be changed to wipe them, in which case the data may not have been wiped.
+// See Finalize.
+func (b *Buffer) ZeroErr() error {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.clear()
+}
+
+// IsZeroed reports whether the buffer's whole data section, written or not, is currently
+// all zeros, for example to assert that Zero took effect. It returns false if the buffer
+// is freed, expired or corrupt.
+func (b *Buffer) IsZeroed() bool {
+	b.mu.Lock()
+	defer b.unlock()
+	if b.canaryCheck() != nil {
+		return false
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+	return allZero(b.data)
+}
+
+// zero wipes the data and resets the write index. b must not be finalized; see clear.
+func (b *Buffer) zero() {
+	wipe(b.data)
+	b.setLen(0)
+}
+
+// clear is zero for a buffer that may be finalized. Wiping is always allowed, but the
+// buffer stays finalized: its pages are made writable for the wipe, and read-only again
+// afterwards. If their protection cannot be changed, the error is returned, and the data
+// may not have been wiped.
+func (b *Buffer) clear() error {
+	if !b.finalized {
+		b.zero()
+		return nil
+	}
+	pages := b.pages(b.data)
+	if err := mprotect(b.p, pages, protReadWrite); err != nil {
+		return err
+	}
+	b.zero()
+	return mprotect(b.p, pages, protRead)
+}
+
+// expire wipes or frees the buffer once its TTL has passed.
+func (b *Buffer) expire() {
+	b.mu.Lock()
+	defer b.unlock()
+	if b.buf == nil {
+		return
+	}
+
+	if b.opts.freeOnExpiry {
+		if err := b.free(); err != nil {
+			panic(err)
+		}
+	} else {
+		// If the wipe fails, the buffer is still marked expired, so its data cannot be
+		// reached through its methods, and Free tries the wipe again and reports the error.
+		_ = b.clear()
+	}
+	b.expired = true
+}
+
+// periodicVerify verifies the buffer for WithPeriodicVerify, and schedules the next
+// check. Corruption is logged by the check, and frees the buffer WithFreeOnCorruption.
+func (b *Buffer) periodicVerify() {
+	b.mu.Lock()
+	if b.buf == nil {
+		b.unlock()
+		return
+	}
+	err := b.verify()
+	corrupt := err == ErrDataCorrupted || err == ErrGuardAccessible
+	if !corrupt {
+		if err != ErrExpired {
+			b.verifier.Reset(b.opts.verifyEvery)
+		}
+		b.unlock()
+		return
+	}
+	b.unlock() // log the corruption before the free
+
+	if b.opts.freeOnCorruption {
+		b.mu.Lock()
+		defer b.unlock()
+		if b.buf != nil {
+			if err := b.free(); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// wipe sets every byte of buf to zero.
+func wipe(buf []byte) {
+	fill(buf, 0)
+}
+
+// fill sets every byte of buf to v.
+func fill(buf []byte, v byte) {
+	if len(buf) == 0 {
+		return
+	}
+	buf[0] = v
 
 	// Based on bytes.Repeat - logn runtime for copying repeated data into a buffer.
-	for i := 1; i < len(b.data); i *= 2 {
-		copy(b.data[i:], b.data[:i])
+	for i := 1; i < len(buf); i *= 2 {
+		copy(buf[i:], buf[:i])
 	}
-	b.i = 0
 }
 
 // Strict sets the buffer to check the integrity of both the canary and any zero padding.
-// By default, only the canary is checked.
+// By default, only the canary is checked. It is shorthand for SetStrict(true).
 func (b *Buffer) Strict() {
-	b.strict = true
+	b.SetStrict(true)
+}
+
+// SetStrict turns strict mode, as set by Strict, on or off.
+func (b *Buffer) SetStrict(on bool) {
+	b.mu.Lock()
+	defer b.unlock()
+	b.strict = on
+}
+
+// Verify checks the integrity of the buffer. It returns ErrAlreadyFreed if the buffer has
+// been freed, or ErrDataCorrupted if the canary (or in strict mode, the padding) has been
+// modified. Every access to the buffer performs the same check. For a buffer allocated
+// WithChecksum, Verify also checks the written data against its checksum, and for one
+// allocated WithGuardVerify, it returns ErrGuardAccessible if a guard page can be read.
+func (b *Buffer) Verify() error {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.verify()
+}
+
+func (b *Buffer) verify() error {
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+	if !b.checksumOK() {
+		b.event = EventCorruption
+		return ErrDataCorrupted
+	}
+	return b.checkGuards()
+}
+
+// writableCheck is canaryCheck for methods that change the buffer's data. It also fails
+// once the buffer is finalized.
+func (b *Buffer) writableCheck() error {
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+	if b.finalized {
+		return ErrFinalized
+	}
+	if b.opts.assertions && (b.i < 0 || b.i > len(b.data)) {
+		return ErrInvariantViolated
+	}
+	return nil
 }
 
 func (b *Buffer) canaryCheck() error {
+	if b.expired {
+		return ErrExpired
+	}
 	if b.buf == nil {
 		return ErrAlreadyFreed
 	}
 	// TODO: Could unroll, since len(canary) is always 16.
 	if !bytes.Equal(b.canary, canary[:]) {
+		b.event = EventCorruption
 		return ErrDataCorrupted
 	}
 
-	if !b.strict || len(b.padding) == 0 {
+	if !b.strict || len(b.padding)+len(b.gap)+len(b.tail) == 0 {
 		return nil
 	}
 
-	for _, v := range b.padding {
-		if v != 0 {
-			return ErrDataCorrupted
-		}
+	if !allZero(b.padding) || !allZero(b.gap) || !allZero(b.tail) {
+		b.event = EventCorruption
+		return ErrDataCorrupted
 	}
 	return nil
 }
 
+// allZero reports whether every byte of p is zero. It ORs p together 8 bytes at a time
+// rather than stopping at the first non-zero byte, so its running time depends only on
+// len(p). The padding holds no secrets, so this is for speed rather than side channels:
+// a branch-free accumulation lets the loop run without mispredictions.
+func allZero(p []byte) bool {
+	var acc uint64
+	for len(p) >= 8 {
+		acc |= binary.LittleEndian.Uint64(p)
+		p = p[8:]
+	}
+	for _, v := range p {
+		acc |= uint64(v)
+	}
+	return acc == 0
+}
+
+// unlock unlocks b, and then logs any event recorded while it was locked and delivers any
+// overflow warning, so that the logger and warning function are free to call b's methods.
+func (b *Buffer) unlock() {
+	event, warn := b.event, b.warn
+	b.event, b.warn = "", false
+	b.mu.Unlock()
+	if event != "" {
+		logEvent(event, b)
+	}
+	if warn {
+		b.opts.overflowWarn(b)
+	}
+}
+
+// physCap returns the physical capacity of the buffer: the largest logical capacity its
+// mapping can hold.
+func (b *Buffer) physCap() int {
+	return len(b.padding) + len(b.gap) + len(b.data) + len(b.tail)
+}
+
+// resize sets the logical capacity of the buffer to n, which must be positive and no
+// larger than its physical capacity. The data is wiped and the write index reset, and the
+// canary moved so that the data still ends at the rear guard. Everything between the
+// front guard and the canary becomes zeroed padding.
+func (b *Buffer) resize(n int) {
+	if b.tag != 0 {
+		n += (tagGranule - n%tagGranule) % tagGranule
+	}
+	b.zero()
+	wipe(b.canary)
+	b.layout(n)
+	retag(b)
+	if n := copy(b.canary, canary[:]); n != CanarySize {
+		panic("copied wrong number of bytes to canary")
+	}
+}
+
+// move changes b's capacity to n in place, keeping its data. Everything between the
+// guard pages other than the data and canary is wiped.
+func (b *Buffer) move(n int) {
+	i := b.i
+	old := b.data[:i]
+	wipe(b.canary)
+	b.layout(n)
+	copy(b.data, old) // copy handles the overlap
+	wipe(b.padding)
+	wipe(b.gap)
+	wipe(b.tail)
+	wipe(b.data[i:])
+	if n := copy(b.canary, canary[:]); n != CanarySize {
+		panic("copied wrong number of bytes to canary")
+	}
+	b.setLen(i)
+}
+
+// layout places the guards, padding, canary and data, with a capacity of n, in b's
+// mapping. By default the data ends at the rear guard, with the canary before it; with
+// WithDataAtPageStart, the data starts at the front guard, with the canary after it.
+// WithCanaryBand moves the canary up to b.gapLen bytes away from the data, and
+// WithRandomizedPadding moves up to b.shift bytes of the padding after the data.
+func (b *Buffer) layout(n int) {
+	d := describeLayout(len(b.buf), n, &b.opts)
+	if b.committed > 0 {
+		// Everything past the committed region guards it.
+		d.Padding.Len = b.committed - d.Padding.Offset
+		d.RearGuard = Region{b.committed, len(b.buf) - b.committed}
+	}
+	gap := Region{d.Canary.Offset, 0}
+	if b.gapLen > 0 {
+		n := min(b.gapLen, d.Padding.Len)
+		d.Padding.Len -= n
+		if b.opts.dataAtPageStart {
+			gap = Region{d.Canary.Offset, n}
+			d.Canary.Offset += n
+			d.Padding.Offset += n
+		} else {
+			d.Canary.Offset -= n
+			gap = Region{d.Canary.Offset + CanarySize, n}
+		}
+	}
+	tail := Region{d.RearGuard.Offset, 0}
+	if b.shift > 0 {
+		shift := min(b.shift, d.Padding.Len) &^ (shiftAlign - 1)
+		d.Padding.Len -= shift
+		d.Canary.Offset -= shift
+		gap.Offset -= shift
+		d.Data.Offset -= shift
+		tail = Region{d.Data.Offset + d.Data.Len, shift}
+	}
+	b.frontGuard = d.FrontGuard.slice(b.buf)
+	b.padding = d.Padding.slice(b.buf)
+	b.canary = d.Canary.slice(b.buf)
+	b.gap = gap.slice(b.buf)
+	b.data = d.Data.slice(b.buf)
+	b.tail = tail.slice(b.buf)
+	b.rearGuard = d.RearGuard.slice(b.buf)
+}
+
+// pages returns the smallest page-aligned region of b.buf that contains region, which must
+// be a sub-slice of b.buf.
+func (b *Buffer) pages(region []byte) []byte {
+	start := cap(b.buf) - cap(region)
+	end := start + len(region)
+	start -= start % pagesize
+	if r := end % pagesize; r != 0 {
+		end += pagesize - r
+	}
+	return b.buf[start:end]
+}
+
+// lock locks each distinct buffer in bs, in allocation order so that concurrent calls
+// cannot deadlock, and returns a function that unlocks them.
+func lock(bs ...*Buffer) (unlock func()) {
+	sorted := make([]*Buffer, 0, len(bs))
+	for _, b := range bs {
+		dup := false
+		for _, s := range sorted {
+			dup = dup || s == b
+		}
+		if !dup {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].id < sorted[j].id
+	})
+
+	for _, b := range sorted {
+		b.mu.Lock()
+	}
+	return func() {
+		for _, b := range sorted {
+			b.unlock()
+		}
+	}
+}
+
 // RequiredBytes returns the number of bytes needed to allocate the requested number of
 // bytes for user access. This is so a user can tell how much memory an alloc will
-// require, and the result should not be passed to Alloc.
+// require, and the result should not be passed to Alloc. It assumes the default of one
+// guard page on each side; see WithGuardPages.
 func RequiredBytes(bytes int) int {
 	needed := bytes + CanarySize
 
@@ -313,5 +1104,5 @@ func init() {
 	if _, err := io.ReadFull(rand.Reader, canary[:]); err != nil {
 		panic(err)
 	}
-	pagesize = syscall.Getpagesize()
+	pagesize = os.Getpagesize()
 }
@@ -1,11 +1,15 @@
 package mlock
 
 import (
+	"bufio"
 	"bytes"
 	"io"
 	"math/rand"
+	"strconv"
 	"syscall"
 	"testing"
+	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/require"
 )
@@ -30,6 +34,61 @@ func TestAlloc(t *testing.T) {
 	require.EqualError(t, err, ErrAlreadyFreed.Error())
 }
 
+func TestIdempotentFree(t *testing.T) {
+	b, err := Alloc(len(text), WithIdempotentFree())
+	require.NoError(t, err)
+	require.NoError(t, b.Free())
+	require.NoError(t, b.Free())
+	require.NoError(t, b.Free())
+	require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+
+	b, err = Alloc(len(text), WithIdempotentFree(), WithTTL(testTTL), WithFreeOnExpiry())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return b.Verify() == ErrExpired
+	}, time.Second, testTTL)
+	require.NoError(t, b.Free())
+}
+
+func TestWithoutSecurity(t *testing.T) {
+	b, err := Alloc(len(text), WithoutSecurity())
+	require.NoError(t, err)
+	require.NoError(t, b.SelfTestGuards())
+	// Reading just past the data runs into the rear guard.
+	require.True(t, faults(b.data[:len(b.data)+1][len(b.data):]))
+	require.NoError(t, b.Free())
+
+	f := newFakeProvider()
+	SetSyscallProvider(f)
+	defer SetSyscallProvider(nil)
+	b, err = Alloc(len(text), WithoutSecurity())
+	require.NoError(t, err)
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	data := b.data
+	require.NoError(t, b.Free())
+	require.Equal(t, text, data, "data wiped on free")
+
+	for _, opt := range []Option{WithLock(), WithoutFork(), WithWipeOnFork()} {
+		_, err = Alloc(len(text), WithoutSecurity(), opt)
+		require.EqualError(t, err, ErrConflictingOptions.Error())
+	}
+}
+
+func TestAllocLatency(t *testing.T) {
+	b, err := Alloc(mb, WithLatencyTracking(), WithParallelPrefault(2))
+	require.NoError(t, err)
+	require.True(t, b.AllocLatency() > 0)
+
+	r, err := b.Realloc(2 * mb)
+	require.NoError(t, err)
+	require.True(t, r.AllocLatency() > 0)
+
+	untracked := allocWith(t, text)
+	require.Zero(t, untracked.AllocLatency())
+	freeAll(t, r, untracked)
+}
+
 const (
 	kb = 1024
 	mb = kb * kb
@@ -103,6 +162,11 @@ func testWriteCorruption(t *testing.T, size int) {
 	n, err = b.Write(text)
 	require.Equal(t, 0, n)
 	require.EqualError(t, err, ErrDataCorrupted.Error())
+
+	b.SetStrict(false)
+	require.NoError(t, b.Verify())
+	b.SetStrict(true)
+	require.EqualError(t, b.Verify(), ErrDataCorrupted.Error())
 	b.padding[7]--
 
 	n, err = b.Write(text)
@@ -217,6 +281,128 @@ func testReadFrom(t *testing.T, size int) {
 	require.NoError(t, err)
 }
 
+// hesitantReader returns (0, nil) pauses times before each chunk of up to chunk bytes of
+// r, which io.Reader allows.
+type hesitantReader struct {
+	r      io.Reader
+	chunk  int
+	pauses int
+	paused int
+}
+
+func (h *hesitantReader) Read(b []byte) (int, error) {
+	if h.paused < h.pauses {
+		h.paused++
+		return 0, nil
+	}
+	h.paused = 0
+	if len(b) > h.chunk {
+		b = b[:h.chunk]
+	}
+	return h.r.Read(b)
+}
+
+func TestReadFromTransientZeros(t *testing.T) {
+	size := pagesize
+	data := make([]byte, size+1)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+
+	b, err := Alloc(size)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Free()) }()
+
+	// Each run of zero reads is under the threshold, so the counter must reset on every
+	// chunk for the whole buffer to be read, including right before it fills.
+	r := &hesitantReader{r: bytes.NewReader(data[:size]), chunk: 100, pauses: progressThresh}
+	n, err := b.ReadFrom(r)
+	require.NoError(t, err)
+	require.Equal(t, int64(size), n)
+	require.Equal(t, data[:size], b.View())
+
+	b.Zero()
+	r = &hesitantReader{r: bytes.NewReader(data), chunk: 100, pauses: progressThresh}
+	n, err = b.ReadFrom(r)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, int64(size), n)
+	require.Equal(t, data[:size], b.View())
+
+	// One more zero read than the threshold is a stall.
+	b.Zero()
+	r = &hesitantReader{r: bytes.NewReader(data), chunk: 100, pauses: progressThresh + 1}
+	n, err = b.ReadFrom(r)
+	require.EqualError(t, err, io.ErrNoProgress.Error())
+	require.Zero(t, n)
+}
+
+func TestCopy(t *testing.T) {
+	for _, s := range sizes {
+		testCopy(t, s)
+	}
+}
+
+func testCopy(t *testing.T, size int) {
+	long := make([]byte, size+1)
+	_, err := rand.Read(long)
+	require.NoError(t, err)
+
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	// io.Copy uses ReadFrom.
+	n, err := io.Copy(b, bytes.NewReader(long))
+	require.Equal(t, int64(size), n)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, long[:size], b.View())
+
+	b.Zero()
+	n, err = io.Copy(b, bytes.NewReader(long[:size]))
+	require.Equal(t, int64(size), n)
+	require.NoError(t, err)
+	require.Equal(t, long[:size], b.View())
+
+	// Hiding ReadFrom makes io.Copy use Write.
+	b.Zero()
+	n, err = io.Copy(struct{ io.Writer }{b}, bytes.NewReader(long))
+	require.Equal(t, int64(size), n)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, long[:size], b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestBufioWriter(t *testing.T) {
+	size := 100
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	long := make([]byte, 3*size)
+	_, err = rand.Read(long)
+	require.NoError(t, err)
+
+	w := bufio.NewWriterSize(b, 16)
+	for i := 0; i < len(long); i += 10 {
+		if _, err = w.Write(long[i : i+10]); err != nil {
+			break
+		}
+	}
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.EqualError(t, w.Flush(), ErrBufferFull.Error())
+	require.Equal(t, long[:size], b.View())
+
+	// bufio.Writer hands large reads straight to ReadFrom.
+	b.Zero()
+	w = bufio.NewWriterSize(b, 16)
+	n, err := w.ReadFrom(bytes.NewReader(long))
+	require.Equal(t, int64(size), n)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, long[:size], b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
 func TestRealloc(t *testing.T) {
 	for _, s := range getSizes() {
 		testRealloc(t, s)
@@ -240,14 +426,12 @@ func testRealloc(t *testing.T, size int) {
 	r, err := b.Realloc(2 * size)
 	require.NoError(t, err)
 	require.Equal(t, long, r.data[:r.i])
-	_, err = b.Write([]byte("freed"))
-	require.EqualError(t, err, ErrAlreadyFreed.Error())
+	requireReallocated(t, b, r)
 
 	r2, err := r.Realloc(3 * size / 2)
 	require.NoError(t, err)
 	require.Equal(t, long, r2.data[:r2.i])
-	_, err = r.Write([]byte("freed"))
-	require.EqualError(t, err, ErrAlreadyFreed.Error())
+	requireReallocated(t, r, r2)
 
 	_, err = r2.Realloc(size / 2)
 	require.EqualError(t, err, ErrBufferTooSmall.Error())
@@ -262,6 +446,101 @@ func testRealloc(t *testing.T, size int) {
 	require.NoError(t, err)
 }
 
+// requireReallocated checks that b was freed by reallocating it to r, unless it was
+// resized in place.
+func requireReallocated(t *testing.T, b, r *Buffer) {
+	if r == b {
+		require.Equal(t, len(b.buf), RequiredBytes(b.Cap()))
+		return
+	}
+	_, err := b.Write([]byte("freed"))
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestReallocInPlace(t *testing.T) {
+	b, err := Alloc(kb)
+	require.NoError(t, err)
+	b.Strict()
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	mapping := &b.buf[0]
+
+	for _, size := range []int{len(text), 2 * kb, pagesize - CanarySize, 100} {
+		r, err := b.Realloc(size)
+		require.NoError(t, err)
+		require.True(t, r == b)
+		require.True(t, &r.buf[0] == mapping)
+		require.Equal(t, size, r.Cap())
+		require.Equal(t, text, r.View())
+		require.Equal(t, make([]byte, size-len(text)), r.ViewCap()[len(text):])
+		require.NoError(t, r.Verify())
+	}
+
+	_, err = b.Realloc(len(text) - 1)
+	require.EqualError(t, err, ErrBufferTooSmall.Error())
+	require.Equal(t, text, b.View())
+
+	// Needing another page, or passing options, allocates a new mapping.
+	r, err := b.Realloc(pagesize)
+	require.NoError(t, err)
+	require.False(t, r == b)
+	r2, err := r.Realloc(pagesize, WithChecksum())
+	require.NoError(t, err)
+	require.False(t, r2 == r)
+	require.NoError(t, r2.Free())
+}
+
+func TestReallocOptions(t *testing.T) {
+	b, err := Alloc(kb, WithChecksum(), WithTTL(time.Hour))
+	require.NoError(t, err)
+	b.Strict()
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	r, err := b.Realloc(2 * kb)
+	require.NoError(t, err)
+	require.True(t, r.strict)
+	require.True(t, r.opts.checksum)
+	require.True(t, r.opts.ttl > 0 && r.opts.ttl <= time.Hour)
+	require.WithinDuration(t, b.deadline, r.deadline, time.Second)
+	require.NoError(t, r.Verify())
+
+	// Options passed to Realloc override those carried over.
+	r2, err := r.Realloc(kb, WithTTL(0))
+	require.NoError(t, err)
+	require.True(t, r2.strict)
+	require.Nil(t, r2.ttl)
+
+	_, err = r2.Realloc(kb, WithoutFork(), WithWipeOnFork())
+	require.EqualError(t, err, ErrConflictingOptions.Error())
+
+	require.NoError(t, r2.Free())
+}
+
+func TestDataAtPageStart(t *testing.T) {
+	b, err := Alloc(len(text), WithDataAtPageStart())
+	require.NoError(t, err)
+
+	start := uintptr(unsafe.Pointer(&b.data[0]))
+	require.Zero(t, start%uintptr(pagesize))
+	require.Equal(t, b.buf[pagesize:pagesize+len(text)], b.data)
+	require.Equal(t, canary[:], b.buf[pagesize+len(text):][:CanarySize])
+	require.NoError(t, b.SelfTestGuards())
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+	require.NoError(t, b.Decommit())
+	require.NoError(t, b.Verify())
+
+	// Overflowing the data now corrupts the canary, rather than faulting.
+	b.data[:len(b.data)+1][len(b.data)] ^= 1
+	require.EqualError(t, b.Verify(), ErrDataCorrupted.Error())
+	b.canary[0] ^= 1
+
+	require.NoError(t, b.Free())
+}
+
 func TestZero(t *testing.T) {
 	for _, s := range getSizes() {
 		testZero(t, s)
@@ -293,6 +572,51 @@ func testZero(t *testing.T, size int) {
 	require.NoError(t, err)
 }
 
+func TestAssertions(t *testing.T) {
+	b, err := Alloc(len(text), WithAssertions())
+	require.NoError(t, err)
+	_, err = b.Write(text[:4])
+	require.NoError(t, err)
+
+	for _, i := range []int{-1, len(text) + 1} {
+		b.i = i
+		_, err = b.Write(text)
+		require.EqualError(t, err, ErrInvariantViolated.Error())
+		require.EqualError(t, b.Seek(0), ErrInvariantViolated.Error())
+		require.EqualError(t, b.SetLen(0), ErrInvariantViolated.Error())
+		_, err = b.ReadFrom(bytes.NewReader(text))
+		require.EqualError(t, err, ErrInvariantViolated.Error())
+	}
+
+	b.i = len(text)
+	require.NoError(t, b.SetLen(0))
+	require.NoError(t, b.Free())
+}
+
+func TestIsZeroed(t *testing.T) {
+	b, err := Alloc(kb)
+	require.NoError(t, err)
+	require.True(t, b.IsZeroed())
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.False(t, b.IsZeroed())
+	b.Zero()
+	require.True(t, b.IsZeroed())
+
+	// Bytes past the written data count too.
+	b.data[kb-1] = 1
+	require.False(t, b.IsZeroed())
+	b.data[kb-1] = 0
+
+	b.canary[0]++
+	require.False(t, b.IsZeroed())
+	b.canary[0]--
+
+	require.NoError(t, b.Free())
+	require.False(t, b.IsZeroed())
+}
+
 func getSizes() []int {
 	s := make([]int, len(sizes))
 	copy(s, sizes)
@@ -301,3 +625,335 @@ func getSizes() []int {
 	}
 	return append(s, bigSizes...)
 }
+
+func TestRewind(t *testing.T) {
+	for _, s := range getSizes() {
+		testRewind(t, s)
+	}
+}
+
+func testRewind(t *testing.T, size int) {
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	long := make([]byte, size)
+	n, err := rand.Read(long)
+	require.Equal(t, n, size)
+	require.NoError(t, err)
+
+	n, err = b.Write(long)
+	require.Equal(t, size, n)
+	require.NoError(t, err)
+
+	keep := size / 2
+	err = b.Rewind(keep)
+	require.NoError(t, err)
+	require.Equal(t, keep, b.Len())
+	require.Equal(t, long[:keep], b.View())
+	require.Equal(t, make([]byte, size-keep), b.data[keep:])
+
+	err = b.Rewind(keep + 1)
+	require.EqualError(t, err, ErrSeekOutOfBounds.Error())
+	err = b.Rewind(-1)
+	require.EqualError(t, err, ErrSeekOutOfBounds.Error())
+	require.Equal(t, keep, b.Len())
+
+	suffix := []byte("new suffix")
+	_, err = b.Write(suffix)
+	if len(suffix) > size-keep {
+		require.EqualError(t, err, ErrBufferFull.Error())
+	} else {
+		require.NoError(t, err)
+		require.Equal(t, append(long[:keep:keep], suffix...), b.View())
+	}
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestSeek(t *testing.T) {
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	err = b.Seek(5)
+	require.NoError(t, err)
+	require.Equal(t, text[:5], b.View())
+
+	for _, i := range []int{-1, -len(text), len(text), len(text) + 1} {
+		err = b.Seek(i)
+		require.EqualError(t, err, ErrSeekOutOfBounds.Error())
+		require.Equal(t, 5, b.Len())
+	}
+
+	err = b.Seek(0)
+	require.NoError(t, err)
+	require.Empty(t, b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+	err = b.Seek(0)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestViewErr(t *testing.T) {
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	v, err := b.ViewErr()
+	require.NoError(t, err)
+	require.NotNil(t, v)
+	require.Empty(t, v)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	v, err = b.ViewErr()
+	require.NoError(t, err)
+	require.Equal(t, text, v)
+
+	b.canary[0]++
+	v, err = b.ViewErr()
+	require.Nil(t, v)
+	require.EqualError(t, err, ErrDataCorrupted.Error())
+	require.Nil(t, b.View())
+	b.canary[0]--
+
+	err = b.Free()
+	require.NoError(t, err)
+	v, err = b.ViewErr()
+	require.Nil(t, v)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+	require.Nil(t, b.View())
+}
+
+func TestViewN(t *testing.T) {
+	b := allocWith(t, text)
+	for _, n := range []int{-1, 0, 1, len(text) - 1, len(text), len(text) + 1, 1 << 20} {
+		v := b.ViewN(n)
+		require.NotNil(t, v)
+		require.True(t, len(v) <= n || n < 0 && len(v) == 0, "n = %d", n)
+		require.True(t, len(v) <= b.Len())
+		require.Equal(t, text[:len(v)], v)
+	}
+	freeAll(t, b)
+	require.Nil(t, b.ViewN(1))
+}
+
+func TestViewCap(t *testing.T) {
+	b, err := Alloc(2 * len(text))
+	require.NoError(t, err)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	v := b.ViewCap()
+	require.Len(t, v, 2*len(text))
+	require.Equal(t, len(v), cap(v))
+	require.Equal(t, text, v[:len(text)])
+
+	copy(v[len(text):], text)
+	require.Equal(t, text, b.View())
+
+	b.canary[0]++
+	require.Nil(t, b.ViewCap())
+	b.canary[0]--
+
+	err = b.Free()
+	require.NoError(t, err)
+	require.Nil(t, b.ViewCap())
+}
+
+func TestSetLen(t *testing.T) {
+	for _, s := range getSizes() {
+		testSetLen(t, s)
+	}
+}
+
+func testSetLen(t *testing.T, size int) {
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	long := make([]byte, size)
+	_, err = rand.Read(long)
+	require.NoError(t, err)
+
+	n := copy(b.ViewCap(), long)
+	require.Equal(t, size, n)
+	require.Empty(t, b.View())
+
+	err = b.SetLen(size)
+	require.NoError(t, err)
+	require.Equal(t, long, b.View())
+
+	err = b.SetLen(size / 2)
+	require.NoError(t, err)
+	require.Equal(t, long[:size/2], b.View())
+
+	for _, i := range []int{-1, size + 1} {
+		err = b.SetLen(i)
+		require.EqualError(t, err, ErrSeekOutOfBounds.Error())
+		require.Equal(t, size/2, b.Len())
+	}
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestResize(t *testing.T) {
+	for _, s := range getSizes() {
+		testResize(t, s)
+	}
+}
+
+func testResize(t *testing.T, size int) {
+	b, err := Alloc(size)
+	require.NoError(t, err)
+	b.Strict()
+
+	phys := b.physCap()
+	require.True(t, phys >= size)
+
+	long := make([]byte, phys+1)
+	_, err = rand.Read(long)
+	require.NoError(t, err)
+
+	for _, n := range []int{1, size / 2, size, phys, 1} {
+		_, err = b.Write(long[:1])
+		require.NoError(t, err)
+
+		b.resize(n)
+		require.Equal(t, n, b.Cap())
+		require.Zero(t, b.Len())
+		require.Equal(t, b.rearGuard, b.buf[cap(b.buf)-cap(b.data)+len(b.data):])
+		require.NoError(t, b.Verify())
+
+		w, err := b.Write(long)
+		require.Equal(t, n, w)
+		require.EqualError(t, err, ErrBufferFull.Error())
+		require.Equal(t, long[:n], b.View())
+		require.NoError(t, b.Verify())
+		b.Zero()
+	}
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestReuse(t *testing.T) {
+	b, err := Alloc(kb, WithChecksum())
+	require.NoError(t, err)
+	mapping := &b.buf[0]
+
+	for _, size := range []int{kb, kb / 2, b.physCap()} {
+		_, err = b.Write(text)
+		require.NoError(t, err)
+
+		r, err := b.Reuse(size)
+		require.NoError(t, err)
+		require.Same(t, b, r)
+		require.Same(t, mapping, &b.buf[0])
+		require.Equal(t, size, b.Cap())
+		require.Zero(t, b.Len())
+		require.Equal(t, make([]byte, size), b.data)
+		require.NoError(t, b.Verify())
+	}
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	phys := b.physCap()
+	r, err := b.Reuse(phys + 1)
+	require.NoError(t, err)
+	require.True(t, r != b)
+	requireReallocated(t, b, r)
+	require.Equal(t, phys+1, r.Cap())
+	require.Zero(t, r.Len())
+	require.True(t, r.opts.checksum)
+	require.NoError(t, r.Free())
+}
+
+func TestVerify(t *testing.T) {
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+	require.NoError(t, b.Verify())
+
+	b.canary[0]++
+	require.EqualError(t, b.Verify(), ErrDataCorrupted.Error())
+	b.canary[0]--
+
+	err = b.Free()
+	require.NoError(t, err)
+	require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+}
+
+// Padding size is pagesize-(size+CanarySize)%pagesize, so smaller sizes scan more padding.
+var verifySizes = []int{16, 256, kb, 2 * kb, 4*kb - CanarySize, 64 * kb}
+
+func BenchmarkVerify(b *testing.B) {
+	for _, s := range verifySizes {
+		b.Run(strconv.Itoa(s), func(b *testing.B) {
+			benchmarkVerify(b, s, false)
+		})
+	}
+}
+
+func BenchmarkVerifyStrict(b *testing.B) {
+	for _, s := range verifySizes {
+		b.Run(strconv.Itoa(s), func(b *testing.B) {
+			benchmarkVerify(b, s, true)
+		})
+	}
+}
+
+func benchmarkVerify(b *testing.B, size int, strict bool) {
+	buf, err := Alloc(size)
+	require.NoError(b, err)
+	if strict {
+		buf.Strict()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := buf.Verify(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	err = buf.Free()
+	require.NoError(b, err)
+}
+
+func TestAllZero(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		p := make([]byte, n)
+		require.True(t, allZero(p))
+		for i := range p {
+			p[i] = 1
+			require.False(t, allZero(p), "len %d, index %d", n, i)
+			p[i] = 0
+		}
+	}
+}
+
+func TestOverflowWarn(t *testing.T) {
+	var warned []int
+	b, err := Alloc(2*CanarySize, WithOverflowWarn(func(b *Buffer) {
+		warned = append(warned, b.Len())
+	}))
+	require.NoError(t, err)
+
+	_, err = b.Write(make([]byte, CanarySize))
+	require.NoError(t, err)
+	require.Empty(t, warned)
+
+	_, err = b.Write([]byte{1})
+	require.NoError(t, err)
+	require.Equal(t, []int{CanarySize + 1}, warned)
+
+	require.NoError(t, b.AppendField(2, nil))
+	require.Equal(t, []int{CanarySize + 1, CanarySize + 3}, warned)
+
+	require.NoError(t, b.Free())
+}
@@ -0,0 +1,21 @@
+package mlock
+
+// MlockAll locks all of the process's current and future memory with
+// mlockall(MCL_CURRENT|MCL_FUTURE), as some hardened daemons do once at startup, so that
+// nothing in the process, including copies of secrets on the Go heap, can be swapped.
+//
+// This is an alternative to locking individual Buffers with WithLock, with different
+// tradeoffs. Every page the process maps is locked and populated as soon as it is mapped,
+// so resident memory grows to cover the whole Go heap, stacks and binary, and the process
+// needs an RLIMIT_MEMLOCK large enough for all of it, or CAP_IPC_LOCK. Once the limit is
+// reached, further allocations fail, which the Go runtime treats as fatal. Per-Buffer
+// locking protects only the secrets, but leaves the rest of the process swappable.
+//
+// Locked pages cannot be released, so after MlockAll, Decommit wipes a Buffer's data by
+// writing zeros to it, like Zero.
+//
+// MlockAll returns the error from mlockall. It returns ErrUnsupported on platforms other
+// than Linux; macOS, for one, does not implement mlockall.
+func MlockAll() error {
+	return mlockall()
+}
@@ -0,0 +1,7 @@
+package mlock
+
+import "syscall"
+
+func mlockall() error {
+	return syscall.Mlockall(syscall.MCL_CURRENT | syscall.MCL_FUTURE)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package mlock
+
+func mlockall() error {
+	return ErrUnsupported
+}
@@ -0,0 +1,85 @@
+package mlock
+
+import (
+	"crypto/rand"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	tagGranule = 16
+
+	prSetTaggedAddrCtrl = 55
+	prTaggedAddrEnable  = 1 << 0
+	prMTETCFSync        = 1 << 1
+	protMTE             = 0x20
+)
+
+var (
+	mteOnce    sync.Once
+	mteEnabled bool
+	mteErr     error
+)
+
+// setTags sets the allocation tag of each 16 byte granule in the n bytes at p to the
+// logical tag in the top byte of p. Both p and n must be granule aligned.
+func setTags(p, n uintptr)
+
+// enableMTE turns on the tagged address ABI and synchronous tag checking for every thread
+// in the process. It reports false without error if the kernel or CPU lacks MTE, or if
+// the binary was built with cgo.
+func enableMTE() (bool, error) {
+	mteOnce.Do(func() {
+		_, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL,
+			prSetTaggedAddrCtrl, prTaggedAddrEnable|prMTETCFSync, 0)
+		switch errno {
+		case 0:
+			mteEnabled = true
+		case syscall.EINVAL, syscall.ENOTSUP:
+			// EINVAL: no MTE in the kernel or CPU. ENOTSUP: AllThreadsSyscall is not
+			// available in binaries built with cgo.
+		default:
+			mteErr = errno
+		}
+	})
+	return mteEnabled, mteErr
+}
+
+func tagMemory(b *Buffer) error {
+	ok, err := enableMTE()
+	if !ok || err != nil {
+		return err
+	}
+	if err := mprotect(b.p, b.buf[len(b.frontGuard):len(b.buf)-len(b.rearGuard)],
+		protReadWrite|protMTE); err != nil {
+		return err
+	}
+
+	var t [1]byte
+	if _, err := rand.Read(t[:]); err != nil {
+		return err
+	}
+	b.tag = t[0]%15 + 1 // never the zero tag of untagged pointers
+	retag(b)
+	return nil
+}
+
+// retag clears the tags of everything between the guard pages, tags the data with b.tag,
+// and points b.data at it through a pointer carrying the same tag. It is a no-op if b is
+// untagged.
+func retag(b *Buffer) {
+	if b.tag == 0 {
+		return
+	}
+
+	base := uintptr(unsafe.Pointer(&b.buf[0]))
+	start := cap(b.buf) - cap(b.data)
+	setTags(base+uintptr(len(b.frontGuard)), uintptr(len(b.buf)-len(b.frontGuard)-len(b.rearGuard)))
+
+	tagged := unsafe.Add(unsafe.Pointer(&b.buf[0]), start+int(b.tag)<<56)
+	setTags(uintptr(tagged), uintptr(len(b.data)))
+
+	// Keep the capacity running to the end of b.buf, which start is computed from.
+	b.data = unsafe.Slice((*byte)(tagged), cap(b.data))[:len(b.data)]
+}
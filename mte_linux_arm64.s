@@ -0,0 +1,14 @@
+#include "textflag.h"
+
+// func setTags(p, n uintptr)
+TEXT ·setTags(SB), NOSPLIT, $0-16
+	MOVD	p+0(FP), R0
+	MOVD	n+8(FP), R1
+loop:
+	CBZ	R1, done
+	WORD	$0xd9200800 // STG X0, [X0]
+	ADD	$16, R0
+	SUB	$16, R1
+	B	loop
+done:
+	RET
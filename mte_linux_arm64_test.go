@@ -0,0 +1,32 @@
+package mlock
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTag(t *testing.T) {
+	b, err := Alloc(100, WithMemoryTag())
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Free()) }()
+	if b.tag == 0 {
+		t.Skip("MTE is not supported by this kernel or CPU")
+	}
+
+	require.Equal(t, 112, b.Cap())
+	_, err = b.Write([]byte("tagged"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("tagged"), b.View())
+	require.NoError(t, b.Decommit())
+	require.NoError(t, b.Verify())
+
+	// Reading the canary through the data's tagged pointer is a tag mismatch.
+	var under []byte
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&under))
+	h.Data = uintptr(unsafe.Pointer(&b.data[0])) - 1
+	h.Len, h.Cap = 1, 1
+	require.True(t, faults(under))
+}
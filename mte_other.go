@@ -0,0 +1,10 @@
+//go:build !linux || !arm64
+// +build !linux !arm64
+
+package mlock
+
+const tagGranule = 1
+
+func tagMemory(b *Buffer) error { return nil }
+
+func retag(b *Buffer) {}
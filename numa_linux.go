@@ -0,0 +1,30 @@
+package mlock
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mpolBind    = 2
+	mpolMFMove  = 1 << 1
+	ulongBits   = 8 * unsafe.Sizeof(uintptr(0))
+	maxNUMANode = 1024
+)
+
+// bindNode binds pages to the NUMA node with mbind(2), moving any already allocated.
+func bindNode(pages []byte, node int) error {
+	if node < 0 || node >= maxNUMANode {
+		return syscall.EINVAL
+	}
+	mask := make([]uintptr, maxNUMANode/ulongBits)
+	mask[uintptr(node)/ulongBits] = 1 << (uintptr(node) % ulongBits)
+
+	_, _, errno := syscall.Syscall6(syscall.SYS_MBIND,
+		uintptr(unsafe.Pointer(&pages[0])), uintptr(len(pages)), mpolBind,
+		uintptr(unsafe.Pointer(&mask[0])), maxNUMANode+1, mpolMFMove)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package mlock
+
+import (
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mpolFAddr = 1 << 1
+
+func TestNUMANode(t *testing.T) {
+	b, err := Alloc(4*pagesize, WithNUMANode(0))
+	if err == syscall.ENOSYS {
+		t.Skip("kernel built without NUMA support")
+	}
+	require.NoError(t, err)
+	defer func() { require.NoError(t, b.Free()) }()
+
+	var mode int32
+	mask := make([]uintptr, maxNUMANode/ulongBits)
+	_, _, errno := syscall.Syscall6(syscall.SYS_GET_MEMPOLICY,
+		uintptr(unsafe.Pointer(&mode)), uintptr(unsafe.Pointer(&mask[0])), maxNUMANode,
+		uintptr(unsafe.Pointer(&b.data[0])), mpolFAddr, 0)
+	require.Zero(t, errno)
+	require.Equal(t, int32(mpolBind), mode)
+	require.Equal(t, uintptr(1), mask[0])
+
+	_, err = Alloc(kb, WithNUMANode(maxNUMANode-1))
+	require.Equal(t, syscall.EINVAL, err)
+}
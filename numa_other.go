@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package mlock
+
+func bindNode(pages []byte, node int) error {
+	return ErrUnsupported
+}
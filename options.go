@@ -0,0 +1,403 @@
+package mlock
+
+import "time"
+
+// Option configures a Buffer allocated by Alloc.
+type Option func(*options)
+
+type options struct {
+	noFork     bool // MADV_DONTFORK
+	wipeOnFork bool // MADV_WIPEONFORK
+	noDump     bool // MADV_DONTDUMP
+
+	lock        bool // mlock(2)
+	lockOnFault bool // mlock2(MLOCK_ONFAULT)
+
+	ttl          time.Duration
+	freeOnExpiry bool
+
+	idempotentFree bool
+
+	verifyEvery      time.Duration
+	freeOnCorruption bool
+
+	compactThreshold int
+
+	memoryTag bool // MTE, linux/arm64 only
+
+	prefaultWorkers int
+
+	checksum bool
+
+	guardVerify      bool
+	guardVerifyEvery time.Duration
+
+	bindNode bool
+	numaNode int
+
+	dataAtPageStart bool
+
+	overflowWarn func(*Buffer)
+
+	guardPagesSet           bool
+	frontGuards, rearGuards int
+
+	reserve int // capacity reserved by AllocReserved
+
+	insecure bool // guards and canary only, for debugging
+
+	latencyTracking bool
+
+	randomPadding bool
+
+	canaryBand int
+
+	assertions bool
+
+	encryptedAtRest bool
+}
+
+func (o *options) validate() error {
+	if o.noFork && o.wipeOnFork {
+		return ErrConflictingOptions
+	}
+	if o.insecure && (o.lock || o.noFork || o.wipeOnFork || o.encryptedAtRest) {
+		return ErrConflictingOptions
+	}
+	if o.randomPadding && o.dataAtPageStart {
+		return ErrConflictingOptions
+	}
+	if o.guardPagesSet && (o.frontGuards < 1 || o.rearGuards < 1) {
+		return ErrGuardPages
+	}
+	return nil
+}
+
+// guardPages returns the number of front and rear guard pages.
+func (o *options) guardPages() (front, rear int) {
+	if !o.guardPagesSet {
+		return 1, 1
+	}
+	return o.frontGuards, o.rearGuards
+}
+
+// capacity returns the capacity of a Buffer allocated with bytes requested, rounded up
+// to a whole number of MTE granules if the Buffer is tagged.
+func (o *options) capacity(bytes int) int {
+	if o.memoryTag {
+		bytes += (tagGranule - bytes%tagGranule) % tagGranule
+	}
+	return bytes
+}
+
+// bandSlack returns the number of bytes WithCanaryBand adds to the canary.
+func (o *options) bandSlack() int {
+	return max(o.canaryBand-CanarySize, 0)
+}
+
+// requiredBytes is RequiredBytes, adjusted for the number of guard pages, any
+// reservation and any canary band.
+func (o *options) requiredBytes(bytes int) int {
+	bytes = max(bytes, o.reserve) + o.bandSlack()
+	front, rear := o.guardPages()
+	return RequiredBytes(bytes) + (front+rear-GuardPages)*pagesize
+}
+
+// WithoutFork excludes the Buffer's memory from any child created by fork(2). The
+// mapping does not exist at all in the child, so a child touching the Buffer will fault.
+//
+// WithoutFork cannot be combined with WithWipeOnFork. It is only supported on Linux.
+func WithoutFork() Option {
+	return func(o *options) {
+		o.noFork = true
+	}
+}
+
+// WithWipeOnFork causes any child created by fork(2) to see the Buffer's memory as all
+// zeros, while the parent retains its contents. Note that the canary is wiped in the
+// child as well, so the child will see the Buffer as corrupted.
+//
+// WithWipeOnFork cannot be combined with WithoutFork. It is only supported on Linux
+// 4.14 and later; on older kernels Alloc returns EINVAL.
+func WithWipeOnFork() Option {
+	return func(o *options) {
+		o.wipeOnFork = true
+	}
+}
+
+// WithoutCoreDump excludes the Buffer's memory from core dumps, so that a crash does not
+// write its data to disk. It is only supported on Linux.
+func WithoutCoreDump() Option {
+	return func(o *options) {
+		o.noDump = true
+	}
+}
+
+// WithLock locks the Buffer's memory with mlock(2), so that it is never swapped to disk.
+// The padding and canary are locked along with the data, and count towards
+// RLIMIT_MEMLOCK; see CanLock. The memory is unlocked when the Buffer is freed.
+//
+// A locked Buffer's Decommit wipes its data like Zero, since locked pages cannot be
+// released. WithLock is only supported on Linux.
+func WithLock() Option {
+	return func(o *options) {
+		o.lock = true
+	}
+}
+
+// WithLockOnFault is like WithLock, but locks each page of the Buffer's memory only once
+// it is first touched, with mlock2(MLOCK_ONFAULT), rather than populating and locking it
+// all up front. A large Buffer that is only partly used then pins only the pages it uses.
+// The whole Buffer still counts towards RLIMIT_MEMLOCK. On Linux before 4.4, which lacks
+// mlock2, it falls back to WithLock's behavior. It is only supported on Linux.
+func WithLockOnFault() Option {
+	return func(o *options) {
+		o.lock = true
+		o.lockOnFault = true
+	}
+}
+
+// WithTTL wipes the Buffer once d has passed since it was allocated. The Buffer's data is
+// zeroed, and every subsequent access returns ErrExpired. The Buffer must still be freed.
+// A non-positive d has no effect.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithFreeOnExpiry frees the Buffer on expiry rather than only zeroing it. Subsequent
+// calls to Free return ErrExpired. It has no effect without WithTTL.
+func WithFreeOnExpiry() Option {
+	return func(o *options) {
+		o.freeOnExpiry = true
+	}
+}
+
+// WithIdempotentFree makes Free return nil, rather than ErrAlreadyFreed or ErrExpired,
+// when the Buffer has already been freed, so that it can be both deferred and called
+// explicitly, like Close. By default, a repeated Free is reported, to catch bugs.
+func WithIdempotentFree() Option {
+	return func(o *options) {
+		o.idempotentFree = true
+	}
+}
+
+// WithPeriodicVerify calls Verify on the Buffer every interval, whether or not it is being
+// used, so that corruption of a rarely accessed Buffer is found, and logged as
+// EventCorruption through the function set by SetLogger. Checks stop once the Buffer is
+// freed, expires or is found corrupt. Each check runs on its own goroutine, so this is
+// meant for a few critical, long-lived Buffers; StartSweeper checks every Buffer from one
+// goroutine. A non-positive interval has no effect.
+func WithPeriodicVerify(interval time.Duration) Option {
+	return func(o *options) {
+		o.verifyEvery = interval
+	}
+}
+
+// WithFreeOnCorruption frees the Buffer when a check by WithPeriodicVerify finds it
+// corrupt, so that it cannot be used any further. It has no effect without
+// WithPeriodicVerify.
+func WithFreeOnCorruption() Option {
+	return func(o *options) {
+		o.freeOnCorruption = true
+	}
+}
+
+// WithCompactThreshold sets the minimum number of pages that Compact must be able to
+// release for it to reallocate the Buffer. The default is one page.
+func WithCompactThreshold(pages int) Option {
+	return func(o *options) {
+		o.compactThreshold = pages
+	}
+}
+
+// WithParallelPrefault touches every page of the Buffer's data from workers goroutines
+// when it is allocated, so that the system backs it with physical memory up front rather
+// than on first use. Splitting the work cuts prefault latency for very large buffers,
+// where each page fault is otherwise taken one after another. A non-positive workers has
+// no effect.
+func WithParallelPrefault(workers int) Option {
+	return func(o *options) {
+		o.prefaultWorkers = workers
+	}
+}
+
+// WithChecksum maintains a CRC-32C of the Buffer's written data, updated as it is written
+// through the Buffer's methods, and checked by Verify. This catches accidental corruption
+// of the data itself, which the canary cannot; it offers no protection against deliberate
+// tampering. Data written directly into a view must be followed by SetLen, which
+// recomputes the checksum, or Verify will report it as corruption.
+func WithChecksum() Option {
+	return func(o *options) {
+		o.checksum = true
+	}
+}
+
+// WithGuardVerify makes Verify probe the Buffer's guard pages as SelfTestGuards does,
+// and return ErrGuardAccessible if either can be read. This detects a guard whose
+// protection has been changed, for example by a stray mprotect elsewhere in the process,
+// which the canary cannot. Each probe faults twice, which is expensive, so Verify probes
+// at most once every interval; an interval of zero probes on every call.
+func WithGuardVerify(every time.Duration) Option {
+	return func(o *options) {
+		o.guardVerify = true
+		o.guardVerifyEvery = every
+	}
+}
+
+// WithNUMANode binds the pages holding the Buffer's data to the given NUMA node with
+// mbind(2), so that a worker pinned to that node accesses its secrets from local memory.
+// Alloc returns the error from mbind if the binding fails: EINVAL if the node does not
+// exist, or ENOSYS if the kernel was built without NUMA support. Nodes from 0 to 1023 are
+// supported. Alloc returns ErrUnsupported on platforms other than Linux.
+func WithNUMANode(node int) Option {
+	return func(o *options) {
+		o.bindNode = true
+		o.numaNode = node
+	}
+}
+
+// WithDataAtPageStart reverses the Buffer's layout, placing the data at the start of the
+// page after the front guard, followed by the canary and padding. The data is then page
+// aligned, for uses such as direct I/O.
+//
+// This changes which errors are caught. By default the data ends at the rear guard, so
+// writing past its end faults immediately, while writing before its start corrupts the
+// canary and is caught by the next check. With WithDataAtPageStart, writing before the
+// start faults on the front guard instead, and writing past the end corrupts the canary.
+func WithDataAtPageStart() Option {
+	return func(o *options) {
+		o.dataAtPageStart = true
+	}
+}
+
+// WithOverflowWarn calls fn whenever a write through one of the Buffer's methods reaches
+// into the last CanarySize bytes of its capacity. Filling a buffer right up to its end is
+// legitimate, but is also what a miscalculated size tends to look like, so this is an
+// early warning for finding such bugs during development, not an error: the write still
+// succeeds. fn is called after the write's method has released the Buffer, so it may call
+// the Buffer's methods, but it must not read or log the Buffer's data.
+func WithOverflowWarn(fn func(b *Buffer)) Option {
+	return func(o *options) {
+		o.overflowWarn = fn
+	}
+}
+
+// WithEncryptedAtRest keeps the Buffer's written data encrypted while it is not being
+// accessed, so that a cold boot attack or a scan of the process's memory finds only
+// ciphertext. The data is encrypted with AES-256 in CTR mode under a random key made for
+// the Buffer, which is kept in a separate locked page excluded from core dumps, and
+// wiped when the Buffer is freed.
+//
+// Data is encrypted as it is written by the Buffer's methods, and data written into a
+// view is encrypted by SetLen. Methods that read the data, such as WriteTo, Equal and
+// HKDF, decrypt it in place while the Buffer is locked, and encrypt it again before
+// returning, as do WithView and Transform around their callbacks. A view cannot be
+// encrypted again once it has been returned, so View and ViewErr do not return one, and
+// the Buffer must be read through WithView instead. Each access costs a pass over the
+// data, and the expanded AES key is briefly on the Go heap while it is used, so this
+// suits secrets that sit idle for long periods between accesses.
+//
+// A Buffer allocated WithEncryptedAtRest cannot be finalized. WithEncryptedAtRest is only
+// supported on Linux.
+func WithEncryptedAtRest() Option {
+	return func(o *options) {
+		o.encryptedAtRest = true
+	}
+}
+
+// WithAssertions checks the Buffer's internal invariants, such as its write index lying
+// within its capacity, at the start of every method that changes its data, and returns
+// ErrInvariantViolated rather than misbehaving or panicking if one does not hold. A
+// violation indicates a bug in this package, so this is a debugging aid for tracking one
+// down; without it, the invariants are assumed.
+func WithAssertions() Option {
+	return func(o *options) {
+		o.assertions = true
+	}
+}
+
+// WithoutSecurity makes the Buffer a debugging allocator for ordinary data, rather than a
+// home for secrets. It keeps the guard pages and canary, so overflows are still caught,
+// but Free skips wiping the data (and filling it with the pattern set by SetFreePoison),
+// leaving that to the system when the memory is unmapped. DO NOT store secrets in such a
+// Buffer: its data may linger in freed memory.
+//
+// WithoutSecurity cannot be combined with WithLock, WithoutFork, WithWipeOnFork or
+// WithEncryptedAtRest.
+func WithoutSecurity() Option {
+	return func(o *options) {
+		o.insecure = true
+	}
+}
+
+// WithLatencyTracking records how long the Buffer took to allocate, for AllocLatency. This
+// helps to profile slow allocations, for example under memory pressure, where locking
+// and prefaulting must wait for pages to be reclaimed. Without it, no time is measured.
+func WithLatencyTracking() Option {
+	return func(o *options) {
+		o.latencyTracking = true
+	}
+}
+
+// WithRandomizedPadding places the data at a random offset within its mapping, so that an
+// attacker who can predict where the mapping is cannot predict exactly where the data
+// and canary are. A random part of the padding, in multiples of 16 bytes, is moved from
+// before the canary to after the data, so the canary still immediately precedes the data.
+// The offset is chosen from the padding the capacity leaves, so it varies least for
+// capacities just short of a multiple of the page size.
+//
+// The cost is that a small overflow of the data lands in the moved padding rather than
+// faulting on the rear guard. Strict mode checks that padding is still zero, so such an
+// overflow is found on the next access. DescribeLayout reports the layout without any
+// padding moved. WithRandomizedPadding cannot be combined with WithDataAtPageStart.
+func WithRandomizedPadding() Option {
+	return func(o *options) {
+		o.randomPadding = true
+	}
+}
+
+// WithCanaryBand reserves bandSize bytes next to the data for the canary, and places the
+// canary at a random offset within them, chosen separately for each allocation. The rest
+// of the band is zero, and strict mode checks that it stays so. An attacker overflowing
+// towards the canary by a fixed amount then cannot predict whether they will hit it, or
+// the zeroed band on either side, which strict mode also catches.
+//
+// The band adds bandSize-CanarySize bytes to the Buffer's memory. DescribeLayout reports
+// the canary next to the data, with the rest of the band counted as padding. A bandSize
+// no larger than CanarySize has no effect.
+func WithCanaryBand(bandSize int) Option {
+	return func(o *options) {
+		o.canaryBand = bandSize
+	}
+}
+
+// WithGuardPages sets the number of guard pages before and after the Buffer's memory,
+// which are one each by default. Wider guards catch accesses that stride further past
+// the Buffer than a single page, at the cost of a page of address space each, but no
+// physical memory. Alloc returns ErrGuardPages if either count is less than one.
+//
+// RequiredBytes assumes the default guards; each extra guard page adds pagesize to it.
+func WithGuardPages(front, rear int) Option {
+	return func(o *options) {
+		o.guardPagesSet = true
+		o.frontGuards = front
+		o.rearGuards = rear
+	}
+}
+
+// WithMemoryTag uses the Memory Tagging Extension to give the Buffer's data an allocation
+// tag that differs from the surrounding canary and padding, so that an out of bounds
+// access through the data trips a hardware fault immediately rather than being caught by
+// the next canary check. The capacity is rounded up to a multiple of the 16 byte tag
+// granule.
+//
+// Tagging requires Linux 5.10 or later on an ARMv8.5 CPU with MTE, and a binary
+// built without cgo, since tag checking must be enabled on every thread of the process.
+// Elsewhere the data is left untagged.
+func WithMemoryTag() Option {
+	return func(o *options) {
+		o.memoryTag = true
+	}
+}
@@ -0,0 +1,60 @@
+package mlock
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWipeOnFork(t *testing.T) {
+	b, err := Alloc(len(text), WithWipeOnFork())
+	if err == syscall.EINVAL {
+		t.Skip("MADV_WIPEONFORK requires Linux 4.14+")
+	}
+	require.NoError(t, err)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	pid, errno := forkCheckZero(b.data)
+	require.Zero(t, errno)
+
+	var status syscall.WaitStatus
+	_, err = syscall.Wait4(int(pid), &status, 0, nil)
+	require.NoError(t, err)
+	require.True(t, status.Exited())
+	require.Equal(t, 0, status.ExitStatus(), "child saw non-zero data")
+
+	require.Equal(t, text, b.View())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestForkOptionsConflict(t *testing.T) {
+	_, err := Alloc(len(text), WithoutFork(), WithWipeOnFork())
+	require.EqualError(t, err, ErrConflictingOptions.Error())
+}
+
+// forkCheckZero forks the process. The child exits with status 0 if data is all zeros,
+// and 1 otherwise. The child must not call into the runtime, so it only uses raw syscalls.
+//
+//go:nosplit
+//go:norace
+func forkCheckZero(data []byte) (uintptr, syscall.Errno) {
+	pid, _, errno := syscall.RawSyscall6(syscall.SYS_CLONE, uintptr(syscall.SIGCHLD), 0, 0, 0, 0, 0)
+	if errno != 0 || pid != 0 {
+		return pid, errno
+	}
+
+	var status uintptr
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0 {
+			status = 1
+		}
+	}
+	for {
+		syscall.RawSyscall(syscall.SYS_EXIT_GROUP, status, 0, 0)
+	}
+}
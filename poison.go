@@ -0,0 +1,14 @@
+package mlock
+
+import "sync/atomic"
+
+var freePoison uint32 // byte written over data by Free
+
+// SetFreePoison sets the byte that Free writes over a buffer's data before unmapping it,
+// in place of zeros. Filling freed data with a recognizable pattern, such as 0xde, makes
+// stale copies of a buffer's contents, or reads through a slice that outlived the buffer,
+// stand out in a debugger or core dump. It is a debugging aid only: Zero and expiry still
+// write zeros, and the default of zero is the right choice in production.
+func SetFreePoison(b byte) {
+	atomic.StoreUint32(&freePoison, uint32(b))
+}
@@ -0,0 +1,32 @@
+package mlock
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreePoison(t *testing.T) {
+	f := newFakeProvider()
+	SetSyscallProvider(f)
+	defer SetSyscallProvider(nil)
+
+	SetFreePoison(0xde)
+	defer SetFreePoison(0)
+
+	b := allocWith(t, text)
+	data := b.data
+	b.Zero()
+	require.Equal(t, make([]byte, len(text)), data)
+
+	freeAll(t, b)
+	require.Len(t, f.unmapped, 1)
+	require.Equal(t, bytes.Repeat([]byte{0xde}, len(text)), data)
+
+	SetFreePoison(0)
+	b = allocWith(t, text)
+	data = b.data
+	freeAll(t, b)
+	require.Equal(t, make([]byte, len(text)), data)
+}
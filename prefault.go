@@ -0,0 +1,30 @@
+package mlock
+
+import "sync"
+
+// prefault writes to every page of data, split between up to workers goroutines. It only
+// touches memory, so must not be given a Buffer's state.
+func prefault(data []byte, workers int) {
+	pages := (len(data) + pagesize - 1) / pagesize
+	if workers > pages {
+		workers = pages
+	}
+	per := (pages + workers - 1) / workers * pagesize
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(data); start += per {
+		end := start + per
+		if end > len(data) {
+			end = len(data)
+		}
+		wg.Add(1)
+		go func(p []byte) {
+			defer wg.Done()
+			for i := 0; i < len(p); i += pagesize {
+				p[i] = 0
+			}
+			p[len(p)-1] = 0 // p may start part way into a page, and end part way into another
+		}(data[start:end])
+	}
+	wg.Wait()
+}
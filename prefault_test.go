@@ -0,0 +1,42 @@
+package mlock
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelPrefault(t *testing.T) {
+	for _, size := range []int{1, pagesize + 100, 64 * pagesize} {
+		for _, workers := range []int{1, 3, 8, 100} {
+			b, err := Alloc(size, WithParallelPrefault(workers))
+			require.NoError(t, err)
+
+			ok, err := b.Resident()
+			if err != ErrUnsupported {
+				require.NoError(t, err)
+				require.True(t, ok, "size %d, workers %d", size, workers)
+			}
+			require.Equal(t, make([]byte, size), b.ViewCap())
+
+			err = b.Free()
+			require.NoError(t, err)
+		}
+	}
+}
+
+// prefaultBenchSize is 1GiB, which still fits in a 32-bit int.
+const prefaultBenchSize = 1 << 30
+
+func BenchmarkParallelPrefault(b *testing.B) {
+	for _, workers := range []int{1, 8} {
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buf, err := Alloc(prefaultBenchSize, WithParallelPrefault(workers))
+				require.NoError(b, err)
+				require.NoError(b, buf.Free())
+			}
+		})
+	}
+}
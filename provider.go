@@ -0,0 +1,75 @@
+package mlock
+
+// Provider performs the system calls used to map, protect and unmap a Buffer's memory.
+//
+// Replacing the default Provider is intended for testing and advanced use only, such as
+// running in a sandbox that proxies mmap. A Provider that does not return real,
+// page-aligned anonymous mappings voids all of the protections offered by this package.
+//
+// On platforms other than Linux and macOS, the default Provider allocates from the Go heap
+// and its Mprotect does nothing. Buffers there keep their canaries and are still wiped on
+// free, so code using this package compiles and runs, but their memory is neither locked
+// nor guarded, and may be copied or swapped by the runtime and the system.
+type Provider interface {
+	// Mmap returns a private, anonymous, readable and writable mapping of length bytes.
+	// The mapping may reuse memory from an earlier one; Alloc zeroes the padding it
+	// checks in strict mode, but the data is left as returned.
+	Mmap(length int) ([]byte, error)
+
+	// Mprotect sets the protection of b, which is a page-aligned sub-slice of a mapping
+	// returned by Mmap, to prot.
+	Mprotect(b []byte, prot int) error
+
+	// Munmap releases a mapping returned by Mmap.
+	Munmap(b []byte) error
+}
+
+var provider Provider = sysProvider{}
+
+// SetSyscallProvider sets the Provider used by subsequent calls to Alloc. Buffers that
+// were already allocated continue to use the Provider that allocated them. If p is nil,
+// the default Provider is restored.
+//
+// SetSyscallProvider must not be called concurrently with Alloc.
+func SetSyscallProvider(p Provider) {
+	if p == nil {
+		p = sysProvider{}
+	}
+	provider = p
+}
+
+// eintrRetries is the number of times a system call interrupted by a signal is retried
+// before the interruption is reported to the caller.
+const eintrRetries = 16
+
+func mmap(p Provider, length int) ([]byte, error) {
+	for i := 0; ; i++ {
+		buf, err := p.Mmap(length)
+		if err != errInterrupted || i == eintrRetries {
+			return buf, err
+		}
+	}
+}
+
+func mprotect(p Provider, b []byte, prot int) error {
+	return retry(func() error {
+		return p.Mprotect(b, prot)
+	})
+}
+
+func munmap(p Provider, b []byte) error {
+	return retry(func() error {
+		return p.Munmap(b)
+	})
+}
+
+// retry calls fn until it returns an error other than EINTR, or it has been retried
+// eintrRetries times.
+func retry(fn func() error) error {
+	for i := 0; ; i++ {
+		err := fn()
+		if err != errInterrupted || i == eintrRetries {
+			return err
+		}
+	}
+}
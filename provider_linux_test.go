@@ -0,0 +1,77 @@
+package mlock
+
+import (
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lazyProvider only reserves address space for its mappings, with MAP_NORESERVE, and
+// records where they are protected, so that layouts can be checked at sizes that could
+// not actually be allocated. Only the pages that are touched, around the canary, are ever
+// backed by memory.
+type lazyProvider struct {
+	mapping   []byte
+	protected []Region
+	unmapped  int
+}
+
+func (l *lazyProvider) Mmap(length int) ([]byte, error) {
+	b, err := syscall.Mmap(-1, 0, length, syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_PRIVATE|syscall.MAP_ANON|syscall.MAP_NORESERVE)
+	if err != nil {
+		return nil, err
+	}
+	l.mapping, l.protected, l.unmapped = b, nil, 0
+	return b, nil
+}
+
+func (l *lazyProvider) Mprotect(b []byte, prot int) error {
+	l.protected = append(l.protected, Region{cap(l.mapping) - cap(b), len(b)})
+	return nil
+}
+
+func (l *lazyProvider) Munmap(b []byte) error {
+	l.unmapped = len(b)
+	return syscall.Munmap(b)
+}
+
+func TestLazyProviderLayout(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("sizes past 2GiB do not fit in a 32-bit int")
+	}
+	l := &lazyProvider{}
+	SetSyscallProvider(l)
+	defer SetSyscallProvider(nil)
+
+	gib := 1 << 30
+	for _, base := range []int{2 * gib, 4 * gib, 16 * gib, 64 * gib} {
+		for _, size := range []int{base - pagesize - 1, base - CanarySize, base - 1, base, base + 1, base + pagesize} {
+			testLazyProviderLayout(t, l, size)
+		}
+	}
+}
+
+func testLazyProviderLayout(t *testing.T, l *lazyProvider, size int) {
+	// WithoutSecurity skips the wipe on Free, which would touch every page.
+	b, err := Alloc(size, WithoutSecurity())
+	require.NoError(t, err)
+
+	n := len(l.mapping)
+	require.Equal(t, RequiredBytes(size), n)
+	require.Zero(t, n%pagesize)
+	require.Equal(t, []Region{{0, pagesize}, {n - pagesize, pagesize}}, l.protected)
+
+	data := n - pagesize - size
+	require.Equal(t, size, b.Cap())
+	require.Equal(t, data, cap(l.mapping)-cap(b.data))
+	require.Equal(t, data-CanarySize, cap(l.mapping)-cap(b.canary))
+	require.Equal(t, canary[:], b.canary)
+	require.Less(t, len(b.padding), pagesize)
+	require.NoError(t, b.Verify())
+
+	require.NoError(t, b.Free())
+	require.Equal(t, n, l.unmapped)
+}
@@ -0,0 +1,32 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package mlock
+
+import "errors"
+
+const (
+	protNone = iota
+	protRead
+	protReadWrite
+)
+
+// errInterrupted is never returned by the heap-backed sysProvider.
+var errInterrupted = errors.New("interrupted")
+
+// sysProvider backs Buffers with the Go heap on platforms without mmap and mprotect. It
+// keeps the package usable there, but offers none of the OS-level protections.
+type sysProvider struct{}
+
+func (sysProvider) Mmap(length int) ([]byte, error) {
+	return make([]byte, length), nil
+}
+
+func (sysProvider) Mprotect(b []byte, prot int) error {
+	return nil
+}
+
+func (sysProvider) Munmap(b []byte) error {
+	wipe(b)
+	return nil
+}
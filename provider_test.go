@@ -0,0 +1,190 @@
+package mlock
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	mapped    [][]byte
+	protected map[*byte]int
+	unmapped  [][]byte
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{protected: make(map[*byte]int)}
+}
+
+func (f *fakeProvider) Mmap(length int) ([]byte, error) {
+	b := make([]byte, length)
+	f.mapped = append(f.mapped, b)
+	return b, nil
+}
+
+func (f *fakeProvider) Mprotect(b []byte, prot int) error {
+	f.protected[&b[0]] = len(b)
+	return nil
+}
+
+func (f *fakeProvider) Munmap(b []byte) error {
+	f.unmapped = append(f.unmapped, b)
+	return nil
+}
+
+func TestFakeProviderLayout(t *testing.T) {
+	f := newFakeProvider()
+	SetSyscallProvider(f)
+	defer SetSyscallProvider(nil)
+
+	for size := 1; size <= 4*pagesize; size += 7 {
+		testFakeProviderLayout(t, f, size)
+	}
+	for _, s := range getSizes() {
+		testFakeProviderLayout(t, f, s)
+	}
+}
+
+func testFakeProviderLayout(t *testing.T, f *fakeProvider, size int) {
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	buf := f.mapped[len(f.mapped)-1]
+	require.Equal(t, RequiredBytes(size), len(buf))
+	require.Zero(t, len(buf)%pagesize)
+
+	require.Equal(t, pagesize, f.protected[&buf[0]])
+	require.Equal(t, pagesize, f.protected[&buf[len(buf)-pagesize]])
+
+	require.Equal(t, size, b.Cap())
+	require.Equal(t, canary[:], buf[len(buf)-pagesize-size-CanarySize:len(buf)-pagesize-size])
+	require.Less(t, len(b.padding), pagesize)
+
+	n, err := b.Write(buf[:0])
+	require.Zero(t, n)
+	require.NoError(t, err)
+
+	err = b.Free()
+	require.NoError(t, err)
+	require.Equal(t, buf, f.unmapped[len(f.unmapped)-1])
+	delete(f.protected, &buf[0])
+	delete(f.protected, &buf[len(buf)-pagesize])
+}
+
+// poolProvider reuses unmapped memory for later mappings of the same length, without
+// clearing it, as a pooling Provider might.
+type poolProvider struct {
+	*fakeProvider
+	free [][]byte
+}
+
+func (p *poolProvider) Mmap(length int) ([]byte, error) {
+	for i, b := range p.free {
+		if len(b) == length {
+			p.free = append(p.free[:i], p.free[i+1:]...)
+			return b, nil
+		}
+	}
+	return p.fakeProvider.Mmap(length)
+}
+
+func (p *poolProvider) Munmap(b []byte) error {
+	p.free = append(p.free, b)
+	return p.fakeProvider.Munmap(b)
+}
+
+func TestReusedMemoryPadding(t *testing.T) {
+	p := &poolProvider{fakeProvider: newFakeProvider()}
+	SetSyscallProvider(p)
+	defer SetSyscallProvider(nil)
+
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+	buf := b.buf
+	require.NoError(t, b.Free())
+	for i := range buf {
+		buf[i] = 0xaa
+	}
+
+	b, err = Alloc(len(text))
+	require.NoError(t, err)
+	require.Equal(t, &buf[0], &b.buf[0])
+	b.Strict()
+	require.NoError(t, b.Verify())
+	require.NoError(t, b.Free())
+}
+
+func TestSetSyscallProviderReset(t *testing.T) {
+	f := newFakeProvider()
+	SetSyscallProvider(f)
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	SetSyscallProvider(nil)
+	require.Equal(t, Provider(sysProvider{}), provider)
+
+	// b must still be released through the provider that mapped it.
+	err = b.Free()
+	require.NoError(t, err)
+	require.Len(t, f.unmapped, 1)
+}
+
+// eintrProvider fails each call with EINTR the given number of times before passing it
+// on to the underlying provider.
+type eintrProvider struct {
+	Provider
+	fails int
+	calls map[string]int
+}
+
+func (e *eintrProvider) interrupted(call string) bool {
+	e.calls[call]++
+	return e.calls[call] <= e.fails
+}
+
+func (e *eintrProvider) Mmap(length int) ([]byte, error) {
+	if e.interrupted("mmap") {
+		return nil, syscall.EINTR
+	}
+	return e.Provider.Mmap(length)
+}
+
+func (e *eintrProvider) Mprotect(b []byte, prot int) error {
+	if e.interrupted("mprotect") {
+		return syscall.EINTR
+	}
+	return e.Provider.Mprotect(b, prot)
+}
+
+func (e *eintrProvider) Munmap(b []byte) error {
+	if e.interrupted("munmap") {
+		return syscall.EINTR
+	}
+	return e.Provider.Munmap(b)
+}
+
+func TestEINTRRetry(t *testing.T) {
+	e := &eintrProvider{Provider: sysProvider{}, fails: 3, calls: make(map[string]int)}
+	SetSyscallProvider(e)
+	defer SetSyscallProvider(nil)
+
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+	require.Equal(t, 4, e.calls["mmap"])
+	require.NoError(t, b.SelfTestGuards())
+
+	err = b.Free()
+	require.NoError(t, err)
+	require.Equal(t, 4, e.calls["munmap"])
+}
+
+func TestEINTRRetryBounded(t *testing.T) {
+	e := &eintrProvider{Provider: newFakeProvider(), fails: 1 << 30, calls: make(map[string]int)}
+	SetSyscallProvider(e)
+	defer SetSyscallProvider(nil)
+
+	_, err := Alloc(len(text))
+	require.EqualError(t, err, syscall.EINTR.Error())
+	require.Equal(t, eintrRetries+1, e.calls["mmap"])
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package mlock
+
+import "syscall"
+
+const (
+	protNone      = syscall.PROT_NONE
+	protRead      = syscall.PROT_READ
+	protReadWrite = syscall.PROT_READ | syscall.PROT_WRITE
+)
+
+// errInterrupted is the error returned by a system call interrupted by a signal.
+var errInterrupted error = syscall.EINTR
+
+type sysProvider struct{}
+
+func (sysProvider) Mmap(length int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+func (sysProvider) Mprotect(b []byte, prot int) error {
+	return syscall.Mprotect(b, prot)
+}
+
+func (sysProvider) Munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
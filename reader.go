@@ -0,0 +1,78 @@
+package mlock
+
+import "io"
+
+// SectionReader reads a Buffer's written data, with its own read offset that is
+// independent of the Buffer's write index. It is created by Buffer.Reader.
+//
+// Each read copies data out of the Buffer into the caller's slice, so the destination
+// should itself be protected memory, or a consumer that does not retain it.
+type SectionReader struct {
+	b   *Buffer
+	off int64
+}
+
+var (
+	_ io.Reader   = (*SectionReader)(nil)
+	_ io.ReaderAt = (*SectionReader)(nil)
+)
+
+// Reader returns a SectionReader over the buffer's written data, starting at its
+// beginning. The reader sees the data as it is at the time of each read. Once the buffer
+// is freed, reads return ErrAlreadyFreed.
+func (b *Buffer) Reader() *SectionReader {
+	return &SectionReader{b: b}
+}
+
+// Read implements io.Reader.
+func (r *SectionReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 && n == len(p) {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt. It returns io.EOF if fewer than len(p) bytes of data
+// follow off.
+func (r *SectionReader) ReadAt(p []byte, off int64) (int, error) {
+	b := r.b
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return 0, err
+	}
+
+	if off < 0 {
+		return 0, ErrSeekOutOfBounds
+	}
+	if off >= int64(b.i) {
+		return 0, io.EOF
+	}
+	b.unseal(b.i)
+	n := copy(p, b.data[off:b.i])
+	b.seal(b.i)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// CopyOut copies the buffer's written data into dst, and returns the number of bytes
+// copied. Unlike copying from View, it never truncates: if dst is shorter than the data,
+// nothing is copied and io.ErrShortBuffer is returned. As with a SectionReader, dst
+// should itself be protected memory, or a consumer that does not retain it.
+func (b *Buffer) CopyOut(dst []byte) (int, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return 0, err
+	}
+	if len(dst) < b.i {
+		return 0, io.ErrShortBuffer
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+	return copy(dst, b.data[:b.i]), nil
+}
@@ -0,0 +1,61 @@
+package mlock
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	b := allocWith(t, text)
+
+	r := b.Reader()
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, text, out)
+
+	// Reading does not move the buffer's write index, and readers are independent.
+	require.Equal(t, len(text), b.Len())
+	p := make([]byte, 4)
+	n, err := b.Reader().Read(p)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	require.Equal(t, text[:4], p)
+
+	n, err = r.ReadAt(p, int64(len(text)-2))
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, text[len(text)-2:], p[:n])
+
+	freeAll(t, b)
+	_, err = r.Read(p)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestCopyOut(t *testing.T) {
+	b := allocWith(t, text)
+
+	exact := make([]byte, len(text))
+	n, err := b.CopyOut(exact)
+	require.NoError(t, err)
+	require.Equal(t, len(text), n)
+	require.Equal(t, text, exact)
+
+	short := make([]byte, len(text)-1)
+	n, err = b.CopyOut(short)
+	require.EqualError(t, err, io.ErrShortBuffer.Error())
+	require.Zero(t, n)
+	require.Equal(t, make([]byte, len(short)), short)
+
+	long := make([]byte, len(text)+8)
+	n, err = b.CopyOut(long)
+	require.NoError(t, err)
+	require.Equal(t, len(text), n)
+	require.Equal(t, text, long[:n])
+	require.Equal(t, make([]byte, 8), long[n:])
+
+	freeAll(t, b)
+	_, err = b.CopyOut(long)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
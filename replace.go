@@ -0,0 +1,37 @@
+package mlock
+
+import "io"
+
+// Replace replaces the buffer's data with everything read from r, for rotating a secret
+// in place. The new data is read into a separate buffer first, allocated with b's
+// options so that it is protected as b is, so if reading fails, or r holds more than the
+// buffer's capacity (ErrBufferFull), the old data is left as it was. Otherwise the old
+// data is wiped and replaced while b stays locked, so no other method call on b can
+// observe a partly replaced value.
+func (b *Buffer) Replace(r io.Reader) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	staged, err := alloc(len(b.data), b.inherited())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := staged.Free(); err != nil {
+			panic(err)
+		}
+	}()
+
+	if _, err := staged.ReadFrom(r); err != nil {
+		return err
+	}
+
+	b.zero()
+	staged.unseal(staged.i)
+	n := copy(b.data, staged.data[:staged.i])
+	b.setLen(n)
+	return nil
+}
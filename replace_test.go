@@ -0,0 +1,70 @@
+package mlock
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errRead = errors.New("read failed")
+
+func TestReplace(t *testing.T) {
+	b, err := Alloc(kb, WithChecksum())
+	require.NoError(t, err)
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	rotated := []byte("rotated")
+	require.NoError(t, b.Replace(bytes.NewReader(rotated)))
+	require.Equal(t, rotated, b.View())
+	require.NoError(t, b.Verify())
+	require.Equal(t, make([]byte, kb-len(rotated)), b.ViewCap()[len(rotated):])
+
+	// Neither a failed read nor an oversized secret disturbs the current value.
+	r := io.MultiReader(bytes.NewReader(text), errReader{errRead})
+	require.Equal(t, errRead, b.Replace(r))
+	require.Equal(t, rotated, b.View())
+
+	err = b.Replace(bytes.NewReader(make([]byte, kb+1)))
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Equal(t, rotated, b.View())
+
+	require.NoError(t, b.Free())
+	err = b.Replace(bytes.NewReader(text))
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestReplaceInheritsOptions(t *testing.T) {
+	b, err := Alloc(kb, WithChecksum())
+	require.NoError(t, err)
+	defer freeAll(t, b)
+
+	// The staging buffer is the newest live buffer while Replace reads into it.
+	var staged options
+	r := readerFunc(func(p []byte) (int, error) {
+		var newest *Buffer
+		for _, l := range liveBuffers() {
+			if newest == nil || l.id > newest.id {
+				newest = l
+			}
+		}
+		staged = newest.opts
+		return copy(p, text), io.EOF
+	})
+	require.NoError(t, b.Replace(r))
+	require.Equal(t, b.opts, staged)
+	require.Equal(t, text, b.View())
+}
+
+// readerFunc is a reader that calls itself.
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+// errReader is a reader that always fails with err.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
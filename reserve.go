@@ -0,0 +1,77 @@
+package mlock
+
+import "sync/atomic"
+
+// AllocReserved allocates a Buffer with a capacity of commit bytes, within a mapping large
+// enough for a capacity of reserve, so that Grow can later extend it up to reserve
+// without moving its data. Only the pages holding the data and canary are accessible and
+// locked; the rest of the reservation is PROT_NONE, and serves as the rear guard. It
+// costs address space, but no memory until it is grown into.
+//
+// The Buffer's data starts at its front guard, as WithDataAtPageStart arranges, so that it
+// can grow in place, and its memory is locked, as WithLock arranges, so the allocation
+// fails if the committed pages cannot be locked, and on platforms where WithLock is not
+// supported.
+//
+// AllocReserved panics if commit is not positive, or reserve is less than commit.
+func AllocReserved(commit, reserve int) (*Buffer, error) {
+	if commit <= 0 {
+		panic("non-positive bytes requested")
+	}
+	if reserve < commit {
+		panic("reservation smaller than commitment")
+	}
+	return alloc(commit, options{dataAtPageStart: true, lock: true, reserve: reserve})
+}
+
+// Grow extends the capacity of a Buffer allocated by AllocReserved to n bytes, making
+// accessible and locking any further pages of its reservation that the data and canary
+// now need. The data stays where it is, and is kept. Growing to no more than the current
+// capacity does nothing.
+//
+// Grow returns ErrNotReserved if b was not allocated by AllocReserved, and ErrBufferFull
+// if n is larger than its reservation.
+func (b *Buffer) Grow(n int) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+	if b.committed == 0 {
+		return ErrNotReserved
+	}
+	if n > b.opts.reserve {
+		return ErrBufferFull
+	}
+	if n <= len(b.data) {
+		return nil
+	}
+
+	if end := roundPage(len(b.frontGuard) + n + CanarySize); end > b.committed {
+		region := b.buf[b.committed:end]
+		if err := mprotect(b.p, region, protReadWrite); err != nil {
+			return err
+		}
+		if err := mlock(region, b.opts.lockOnFault); err != nil {
+			if e := mprotect(b.p, region, protNone); e != nil {
+				panic(e)
+			}
+			return err
+		}
+		b.locked += len(region)
+		atomic.AddInt64(&lockedBytes, int64(len(region)))
+		b.committed = end
+	}
+
+	wipe(b.canary)
+	b.layout(n)
+	if n := copy(b.canary, canary[:]); n != CanarySize {
+		panic("copied wrong number of bytes to canary")
+	}
+	return nil
+}
+
+// roundPage rounds n up to a whole number of pages.
+func roundPage(n int) int {
+	return (n + pagesize - 1) / pagesize * pagesize
+}
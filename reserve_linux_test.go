@@ -0,0 +1,51 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocReserved(t *testing.T) {
+	defer assertNoLeaks(t)()
+
+	b, err := AllocReserved(kb, 64*kb)
+	require.NoError(t, err)
+	require.Equal(t, kb, b.Cap())
+	require.Equal(t, RequiredBytes(64*kb), len(b.buf))
+	require.Equal(t, pagesize, b.locked)
+	ok, err := b.guardsProtected()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	base, mapping := &b.data[0], &b.buf[0]
+
+	for _, n := range []int{2 * kb, 32 * kb, 64 * kb} {
+		require.NoError(t, b.Grow(n))
+		require.Equal(t, n, b.Cap())
+		require.Same(t, base, &b.data[0])
+		require.Same(t, mapping, &b.buf[0])
+		require.Equal(t, text, b.View())
+		require.Equal(t, roundPage(n+CanarySize), b.locked)
+
+		ok, err = b.guardsProtected()
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.NoError(t, b.SelfTestGuards())
+	}
+
+	// The whole capacity is usable.
+	require.NoError(t, b.SetLen(b.Cap()))
+	require.NoError(t, b.Verify())
+
+	require.EqualError(t, b.Grow(64*kb+1), ErrBufferFull.Error())
+	require.NoError(t, b.Grow(kb))
+	require.Equal(t, 64*kb, b.Cap())
+	require.NoError(t, b.Free())
+
+	b = allocWith(t, text)
+	require.EqualError(t, b.Grow(2*len(text)), ErrNotReserved.Error())
+	freeAll(t, b)
+}
@@ -0,0 +1,15 @@
+package mlock
+
+// Resident reports whether every page holding the buffer's data is currently resident in
+// RAM. It can be used to confirm that the data has not been paged out right before it is
+// used. The result is only a snapshot, and may be stale as soon as it is returned.
+//
+// Resident returns ErrUnsupported on platforms without mincore(2).
+func (b *Buffer) Resident() (bool, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return false, err
+	}
+	return resident(b.pages(b.data))
+}
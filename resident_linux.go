@@ -0,0 +1,26 @@
+package mlock
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func resident(pages []byte) (bool, error) {
+	vec := make([]byte, len(pages)/pagesize)
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_MINCORE,
+		uintptr(unsafe.Pointer(&pages[0])),
+		uintptr(len(pages)),
+		uintptr(unsafe.Pointer(&vec[0])),
+	)
+	if errno != 0 {
+		return false, errno
+	}
+
+	for _, v := range vec {
+		if v&1 == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,38 @@
+package mlock
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResident(t *testing.T) {
+	size := 4 * pagesize
+	b, err := Alloc(size)
+	require.NoError(t, err)
+
+	err = syscall.Mlock(b.data)
+	require.NoError(t, err)
+
+	ok, err := b.Resident()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	err = syscall.Munlock(b.data)
+	require.NoError(t, err)
+
+	// The last page holds only data, so dropping it leaves the canary intact.
+	err = syscall.Madvise(b.data[size-pagesize:], syscall.MADV_DONTNEED)
+	require.NoError(t, err)
+
+	ok, err = b.Resident()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	_, err = b.Resident()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
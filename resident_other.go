@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package mlock
+
+func resident(pages []byte) (bool, error) {
+	return false, ErrUnsupported
+}
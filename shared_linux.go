@@ -0,0 +1,121 @@
+package mlock
+
+import (
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// NewSharedBuffer allocates a Buffer whose memory is backed by a sealed memfd, so that it
+// can be shared with another process by passing it the returned file descriptor. The
+// caller owns fd and must close it once it has been passed on; the Buffer itself remains
+// valid until freed.
+//
+// The memfd holds the whole mapping, including the canary and the pages this process uses
+// as guards, which hold nothing; guard protection is private to each mapping. Before the
+// memfd is mapped, it is sealed with F_SEAL_SHRINK and F_SEAL_GROW, and with F_SEAL_SEAL
+// so the seals cannot be changed. A peer therefore cannot truncate the memfd to make this
+// process fault, or extend it, but it can read and write the shared data and corrupt the
+// canary. Only share a Buffer with a trusted process.
+//
+// NewSharedBuffer is only supported on Linux 3.17 and later. It panics if size is not
+// positive.
+func NewSharedBuffer(size int) (b *Buffer, fd int, err error) {
+	if size <= 0 {
+		panic("non-positive bytes requested")
+	}
+
+	needed := RequiredBytes(size)
+	fd, err = newMemfd(needed)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer func() {
+		if err != nil {
+			syscall.Close(fd)
+			fd = -1
+		}
+	}()
+
+	if err = seal(fd, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_SEAL); err != nil {
+		return nil, fd, err
+	}
+
+	buf, err := syscall.Mmap(fd, 0, needed, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fd, err
+	}
+
+	b, err = newBuffer(sysProvider{}, buf, size, options{}, time.Time{})
+	if err != nil {
+		return nil, fd, err
+	}
+	b.shared = true
+	return b, fd, nil
+}
+
+// ToMemfd copies the buffer's data into a new memfd of exactly its length, and returns
+// the memfd, for example to pass the data to a child process through exec.Cmd's
+// ExtraFiles. The data is copied directly from the buffer into the memfd's memory, and
+// the memfd is then sealed against any change, so the recipient can only read it. The
+// caller owns fd and must close it.
+//
+// The memfd's memory is not locked, and may be swapped. A recipient that maps it should
+// mlock its own mapping, and should copy the data somewhere safe and close the memfd as
+// soon as possible, since the data lives as long as any descriptor to it.
+//
+// ToMemfd is only supported on Linux 3.17 and later.
+func (b *Buffer) ToMemfd() (fd int, err error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return -1, err
+	}
+
+	fd, err = newMemfd(b.i)
+	if err != nil {
+		return -1, err
+	}
+	defer func() {
+		if err != nil {
+			syscall.Close(fd)
+			fd = -1
+		}
+	}()
+
+	if b.i > 0 {
+		m, err := syscall.Mmap(fd, 0, b.i, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+		if err != nil {
+			return fd, err
+		}
+		b.unseal(b.i)
+		copy(m, b.data[:b.i])
+		b.seal(b.i)
+		// F_SEAL_WRITE cannot be added while a writable shared mapping exists.
+		if err := syscall.Munmap(m); err != nil {
+			return fd, err
+		}
+	}
+
+	err = seal(fd, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+	return fd, err
+}
+
+// newMemfd returns a new, sealable memfd of the given size.
+func newMemfd(size int) (int, error) {
+	fd, err := unix.MemfdCreate("mlock", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return -1, err
+	}
+	if err := syscall.Ftruncate(fd, int64(size)); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+func seal(fd, seals int) error {
+	_, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, seals)
+	return err
+}
@@ -0,0 +1,84 @@
+package mlock
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSharedBuffer(t *testing.T) {
+	b, fd, err := NewSharedBuffer(len(text))
+	require.NoError(t, err)
+	defer syscall.Close(fd)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.NoError(t, b.SelfTestGuards())
+
+	var st syscall.Stat_t
+	require.NoError(t, syscall.Fstat(fd, &st))
+	size := st.Size
+	require.Equal(t, int64(RequiredBytes(len(text))), size)
+
+	require.Equal(t, syscall.EPERM, syscall.Ftruncate(fd, size+int64(pagesize)))
+	require.Equal(t, syscall.EPERM, syscall.Ftruncate(fd, size-int64(pagesize)))
+
+	// A peer mapping the memfd sees the data.
+	peer, err := syscall.Mmap(fd, 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	require.NoError(t, err)
+	require.Equal(t, text, peer[int(size)-pagesize-len(text):int(size)-pagesize])
+	require.NoError(t, syscall.Munmap(peer))
+
+	require.NoError(t, b.Free())
+}
+
+func TestDecommitShared(t *testing.T) {
+	size := 8 * pagesize
+	b, fd, err := NewSharedBuffer(size)
+	require.NoError(t, err)
+	defer syscall.Close(fd)
+
+	_, err = b.Write(bytes.Repeat([]byte{1}, size))
+	require.NoError(t, err)
+	require.NoError(t, b.Decommit())
+	require.Zero(t, b.Len())
+
+	// MADV_DONTNEED leaves the memfd's pages intact, so they must have been wiped.
+	data := make([]byte, size)
+	_, err = syscall.Pread(fd, data, int64(RequiredBytes(size)-pagesize-size))
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, size), data)
+
+	require.NoError(t, b.Free())
+}
+
+func TestToMemfd(t *testing.T) {
+	b, err := Alloc(kb)
+	require.NoError(t, err)
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	fd, err := b.ToMemfd()
+	require.NoError(t, err)
+	defer syscall.Close(fd)
+
+	// The memfd can be read, but not written or resized.
+	out, err := syscall.Mmap(fd, 0, len(text), syscall.PROT_READ, syscall.MAP_SHARED)
+	require.NoError(t, err)
+	require.Equal(t, text, out)
+	require.NoError(t, syscall.Munmap(out))
+
+	_, err = syscall.Mmap(fd, 0, len(text), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	require.Equal(t, syscall.EPERM, err)
+	_, err = syscall.Pwrite(fd, text, 0)
+	require.Equal(t, syscall.EPERM, err)
+	require.Equal(t, syscall.EPERM, syscall.Ftruncate(fd, 0))
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	_, err = b.ToMemfd()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
@@ -0,0 +1,141 @@
+package mlock
+
+import (
+	"sort"
+	"sync"
+)
+
+// SizeClassCache caches freed Buffers by size class, so that a workload allocating a few
+// common sizes reuses mappings rather than mapping and unmapping memory for each Buffer.
+// Each request is served from the smallest class that fits it, so Buffers of similar
+// sizes share mappings and the cache does not fragment into one pool per exact size.
+//
+// A SizeClassCache is safe for concurrent use. Its Buffers are ordinary Buffers, and may
+// be freed rather than returned with Put; the cached Buffers are freed by Free.
+type SizeClassCache struct {
+	classes []int
+	opts    []Option
+
+	mu     sync.Mutex
+	cached [][]*Buffer // cached[i] holds Buffers that fit classes[i]
+}
+
+// NewSizeClassCache returns a cache with the given size classes, in bytes, whose Buffers
+// are allocated with opts. Classes that are not positive are ignored. PageSizeClasses
+// returns classes that each fill a power-of-two number of pages.
+func NewSizeClassCache(classes []int, opts ...Option) *SizeClassCache {
+	var sorted []int
+	for _, class := range classes {
+		if class > 0 {
+			sorted = append(sorted, class)
+		}
+	}
+	sort.Ints(sorted)
+	return &SizeClassCache{
+		classes: sorted,
+		opts:    opts,
+		cached:  make([][]*Buffer, len(sorted)),
+	}
+}
+
+// PageSizeClasses returns n size classes for NewSizeClassCache, of 1, 2, 4 and so on up
+// to 1<<(n-1) pages. Each is the largest capacity that fits in its number of pages, so
+// its Buffers waste none of their memory.
+func PageSizeClasses(n int) []int {
+	classes := make([]int, n)
+	for i := range classes {
+		classes[i] = pagesize<<i - CanarySize
+	}
+	return classes
+}
+
+// Get returns an empty Buffer with a capacity of size bytes, reusing a cached Buffer of
+// the smallest class that fits size if there is one, and otherwise allocating a Buffer of
+// that class. A size larger than every class is allocated with Alloc as is. A cached
+// Buffer that cannot be reused, such as one that has expired, is freed, and an error
+// from freeing it is returned.
+//
+// Get panics if size is not positive.
+func (c *SizeClassCache) Get(size int) (*Buffer, error) {
+	if size <= 0 {
+		panic("non-positive size requested")
+	}
+	i := sort.SearchInts(c.classes, size)
+	if i == len(c.classes) {
+		return Alloc(size, c.opts...)
+	}
+
+	for {
+		c.mu.Lock()
+		cached := c.cached[i]
+		if len(cached) == 0 {
+			c.mu.Unlock()
+			break
+		}
+		b := cached[len(cached)-1]
+		c.cached[i] = cached[:len(cached)-1]
+		c.mu.Unlock()
+
+		// A cached Buffer may have expired since it was put, and been freed if it was
+		// allocated WithFreeOnExpiry.
+		if r, err := b.Reuse(size); err == nil {
+			return r, nil
+		}
+		if err := b.Free(); err != nil && err != ErrAlreadyFreed {
+			return nil, err
+		}
+	}
+
+	b, err := Alloc(c.classes[i], c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return b.Reuse(size)
+}
+
+// Put zeroes b, clears its label and strict mode, and caches it in the largest class its
+// mapping can hold, for a later Get.
+// A Buffer too small for every class is freed. If b has been freed, has expired, is
+// corrupt or is finalized, Put returns the error and does not take b.
+//
+// Put should only be given Buffers from Get, so that every cached Buffer has the
+// cache's options. b must not be used after a successful Put.
+func (c *SizeClassCache) Put(b *Buffer) error {
+	b.mu.Lock()
+	if err := b.writableCheck(); err != nil {
+		b.unlock()
+		return err
+	}
+	b.zero()
+	b.label, b.strict = "", false
+	physCap := b.physCap()
+	b.unlock()
+
+	i := sort.SearchInts(c.classes, physCap+1) - 1
+	if i < 0 {
+		return b.Free()
+	}
+	c.mu.Lock()
+	c.cached[i] = append(c.cached[i], b)
+	c.mu.Unlock()
+	return nil
+}
+
+// Free frees every cached Buffer, returning the first error encountered. The cache may
+// still be used afterwards.
+func (c *SizeClassCache) Free() error {
+	c.mu.Lock()
+	cached := c.cached
+	c.cached = make([][]*Buffer, len(c.classes))
+	c.mu.Unlock()
+
+	var err error
+	for _, bs := range cached {
+		for _, b := range bs {
+			if e := b.Free(); err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
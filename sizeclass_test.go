@@ -0,0 +1,117 @@
+package mlock
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeClassCache(t *testing.T) {
+	classes := PageSizeClasses(3)
+	require.Equal(t, []int{pagesize - CanarySize, 2*pagesize - CanarySize, 4*pagesize - CanarySize}, classes)
+	c := NewSizeClassCache(append(classes, 0))
+
+	b, err := c.Get(100)
+	require.NoError(t, err)
+	require.Equal(t, 100, b.Cap())
+	require.Equal(t, classes[0], b.physCap())
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	data := b.data
+	b.SetLabel("session-key")
+	b.Strict()
+
+	// The cached Buffer is zeroed, and reused for any size in its class.
+	require.NoError(t, c.Put(b))
+	require.Equal(t, make([]byte, len(text)), data[:len(text)])
+	r, err := c.Get(classes[0])
+	require.NoError(t, err)
+	require.Same(t, b, r)
+	require.Equal(t, classes[0], r.Cap())
+	require.Zero(t, r.Len())
+	require.Empty(t, r.Label())
+	require.False(t, r.strict)
+	require.NotContains(t, ListLabels(), "session-key")
+
+	// A size just past a class is served from the next one.
+	r2, err := c.Get(classes[0] + 1)
+	require.NoError(t, err)
+	require.Equal(t, classes[1], r2.physCap())
+
+	// Sizes past every class bypass the cache.
+	big, err := c.Get(classes[2] + 1)
+	require.NoError(t, err)
+	require.Equal(t, classes[2]+1, big.Cap())
+	require.NoError(t, c.Put(big))
+	require.Len(t, c.cached[2], 1)
+
+	// Buffers smaller than every class are freed.
+	small, err := Alloc(100)
+	require.NoError(t, err)
+	small.resize(10)
+	require.NoError(t, NewSizeClassCache([]int{2 * pagesize}).Put(small))
+	require.EqualError(t, small.Free(), ErrAlreadyFreed.Error())
+
+	require.NoError(t, r.Free())
+	require.EqualError(t, c.Put(r), ErrAlreadyFreed.Error())
+
+	require.NoError(t, c.Put(r2))
+	require.NoError(t, c.Free())
+	require.EqualError(t, r2.Free(), ErrAlreadyFreed.Error())
+	require.EqualError(t, big.Free(), ErrAlreadyFreed.Error())
+}
+
+// failingUnmapProvider is a fakeProvider whose Munmap always fails.
+type failingUnmapProvider struct{ *fakeProvider }
+
+var errUnmap = errors.New("munmap failed")
+
+func (failingUnmapProvider) Munmap([]byte) error { return errUnmap }
+
+func TestSizeClassCacheFreeError(t *testing.T) {
+	SetSyscallProvider(failingUnmapProvider{newFakeProvider()})
+	defer SetSyscallProvider(nil)
+
+	c := NewSizeClassCache(PageSizeClasses(1))
+	b, err := c.Get(100)
+	require.NoError(t, err)
+	require.NoError(t, c.Put(b))
+
+	// b cannot be reused once corrupt, or freed, so Get reports why.
+	b.canary[0] ^= 1
+	_, err = c.Get(100)
+	require.Equal(t, errUnmap, err)
+
+	b.p = newFakeProvider()
+	require.NoError(t, b.Free())
+}
+
+// mixedSizes is a workload of a few common sizes.
+var mixedSizes = []int{32, 64, 100, 1000, 3000, 5000}
+
+func BenchmarkSizeClassCache(b *testing.B) {
+	c := NewSizeClassCache(PageSizeClasses(2))
+	defer c.Free()
+	for i := 0; i < b.N; i++ {
+		buf, err := c.Get(mixedSizes[i%len(mixedSizes)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := c.Put(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAllocMixedSizes(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		buf, err := Alloc(mixedSizes[i%len(mixedSizes)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := buf.Free(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
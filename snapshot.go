@@ -0,0 +1,57 @@
+package mlock
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+)
+
+// Snapshot encrypts the buffer's data with aead under a random nonce, and returns the
+// nonce followed by the ciphertext. The snapshot can be stored or sent anywhere, for
+// example to checkpoint a process, and later turned back into a Buffer with Restore. The
+// data is encrypted directly from the buffer.
+func (b *Buffer) Snapshot(aead cipher.AEAD) ([]byte, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return nil, err
+	}
+
+	snapshot := make([]byte, aead.NonceSize(), aead.NonceSize()+b.i+aead.Overhead())
+	if _, err := rand.Read(snapshot); err != nil {
+		return nil, err
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+	return aead.Seal(snapshot, snapshot, b.data[:b.i], nil), nil
+}
+
+// Restore decrypts a snapshot made by Snapshot with the same aead into a new Buffer,
+// whose capacity is the length of the data, allocated with opts. The data is decrypted
+// directly into the Buffer. As with Alloc, the Buffer is not locked unless opts include
+// WithLock, which a snapshot of a locked Buffer usually should. Restore returns
+// ErrInvalidSnapshot if the snapshot is too short to be valid, and the error from aead if
+// it fails to authenticate.
+//
+// Since a Buffer cannot be empty, the snapshot of an empty Buffer restores to an empty
+// Buffer with a capacity of one byte.
+func Restore(aead cipher.AEAD, snapshot []byte, opts ...Option) (*Buffer, error) {
+	if len(snapshot) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidSnapshot
+	}
+	nonce, ct := snapshot[:aead.NonceSize()], snapshot[aead.NonceSize():]
+	n := len(ct) - aead.Overhead()
+
+	b, err := Alloc(max(n, 1), opts...)
+	if err != nil {
+		return nil, err
+	}
+	pt, err := aead.Open(b.data[:0:n], nonce, ct, nil)
+	if err != nil {
+		if e := b.Free(); e != nil {
+			panic(e)
+		}
+		return nil, err
+	}
+	b.setLen(len(pt))
+	return b, nil
+}
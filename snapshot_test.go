@@ -0,0 +1,53 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	aead := newTestAEAD(t)
+	b := allocWith(t, text)
+	defer freeAll(t, b)
+
+	snapshot, err := b.Snapshot(aead)
+	require.NoError(t, err)
+	require.NotContains(t, string(snapshot), string(text))
+
+	r, err := Restore(aead, snapshot)
+	require.NoError(t, err)
+	require.Equal(t, text, r.View())
+	require.Equal(t, len(text), r.Cap())
+	freeAll(t, r)
+
+	// Options apply to the restored Buffer.
+	r, err = Restore(aead, snapshot, WithChecksum())
+	require.NoError(t, err)
+	require.True(t, r.opts.checksum)
+	require.NoError(t, r.Verify())
+	freeAll(t, r)
+
+	// Each snapshot uses a fresh nonce.
+	again, err := b.Snapshot(aead)
+	require.NoError(t, err)
+	require.NotEqual(t, snapshot, again)
+
+	snapshot[len(snapshot)-1] ^= 1
+	r, err = Restore(aead, snapshot)
+	require.Error(t, err)
+	require.Nil(t, r)
+
+	_, err = Restore(aead, snapshot[:aead.NonceSize()])
+	require.EqualError(t, err, ErrInvalidSnapshot.Error())
+
+	empty, err := Alloc(kb)
+	require.NoError(t, err)
+	defer freeAll(t, empty)
+	snapshot, err = empty.Snapshot(aead)
+	require.NoError(t, err)
+	r, err = Restore(aead, snapshot)
+	require.NoError(t, err)
+	require.Equal(t, 0, r.Len())
+	freeAll(t, r)
+}
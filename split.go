@@ -0,0 +1,130 @@
+package mlock
+
+// Split moves the buffer's written data into two new Buffers, the first holding the data
+// before at and the second the rest, and then frees b. Each part gets its own guarded
+// mapping, with a capacity of its length, and the options b was allocated with, as for
+// Realloc. Split returns ErrSeekOutOfBounds if at is negative or past b's length.
+//
+// Since a Buffer cannot be empty, a part with no data is an empty Buffer with a capacity
+// of one byte.
+func (b *Buffer) Split(at int) (first, second *Buffer, err error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return nil, nil, err
+	}
+	if at < 0 || at > b.i {
+		return nil, nil, ErrSeekOutOfBounds
+	}
+
+	o := b.inherited()
+	b.unseal(b.i)
+	defer b.seal(b.i) // b is only still mapped on error
+	parts := [2][]byte{b.data[:at], b.data[at:b.i]}
+	var bs [2]*Buffer
+	for i, p := range parts {
+		if bs[i], err = alloc(max(len(p), 1), o); err != nil {
+			if bs[0] != nil {
+				if e := bs[0].Free(); e != nil {
+					panic(e)
+				}
+			}
+			return nil, nil, err
+		}
+		bs[i].strict = b.strict
+		bs[i].setLen(copy(bs[i].data, p))
+	}
+
+	if err := b.free(); err != nil {
+		for _, p := range bs {
+			if e := p.Free(); e != nil {
+				panic(e)
+			}
+		}
+		return nil, nil, err
+	}
+	return bs[0], bs[1], nil
+}
+
+// Concat allocates a Buffer holding the written data of each of bs in order, with a
+// capacity of their total length, copying directly between the Buffers. Each of bs is
+// checked for integrity first. The new Buffer is allocated with default options.
+//
+// Since a Buffer cannot be empty, if bs hold no data, Concat returns an empty Buffer with
+// a capacity of one byte.
+func Concat(bs ...*Buffer) (*Buffer, error) {
+	return concat(bs, false)
+}
+
+// ConcatFree is like Concat, but also frees each of bs once its data has been copied. If
+// Concat fails, none of bs is freed.
+func ConcatFree(bs ...*Buffer) (*Buffer, error) {
+	return concat(bs, true)
+}
+
+func concat(bs []*Buffer, free bool) (r *Buffer, err error) {
+	defer lock(bs...)()
+	n := 0
+	for _, b := range bs {
+		if err := b.canaryCheck(); err != nil {
+			return nil, err
+		}
+		n += b.i
+	}
+
+	r, err = Alloc(max(n, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if e := r.Free(); e != nil {
+			panic(e)
+		}
+		r = nil
+	}()
+
+	n = 0
+	for _, b := range bs {
+		b.unseal(b.i)
+		n += copy(r.data[n:], b.data[:b.i])
+		b.seal(b.i)
+	}
+	r.setLen(n)
+
+	if !free {
+		return r, nil
+	}
+	for _, b := range bs {
+		if b.buf == nil {
+			continue // passed more than once
+		}
+		if err := b.free(); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// AppendFrom appends src's written data to b, copying directly between the Buffers, and
+// returns the number of bytes appended. Both Buffers are checked for integrity first. If
+// the data does not fit in b, nothing is appended and ErrBufferFull is returned. src is
+// left unchanged; Buffers have no read position to advance.
+func (b *Buffer) AppendFrom(src *Buffer) (int, error) {
+	defer lock(b, src)()
+	if err := b.writableCheck(); err != nil {
+		return 0, err
+	}
+	if err := src.canaryCheck(); err != nil {
+		return 0, err
+	}
+	if src.i > len(b.data)-b.i {
+		return 0, ErrBufferFull
+	}
+	// src may be b, so reseal only the bytes that were unsealed.
+	src.unseal(src.i)
+	defer src.seal(src.i)
+	return b.write(src.data[:src.i])
+}
@@ -0,0 +1,93 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	for _, at := range []int{0, 16, len(text)} {
+		b, err := Alloc(2*len(text), WithChecksum())
+		require.NoError(t, err)
+		_, err = b.Write(text)
+		require.NoError(t, err)
+
+		first, second, err := b.Split(at)
+		require.NoError(t, err)
+		require.Equal(t, text[:at], first.View())
+		require.Equal(t, text[at:], second.View())
+		require.Equal(t, max(at, 1), first.Cap())
+		require.Equal(t, max(len(text)-at, 1), second.Cap())
+		require.True(t, first.opts.checksum)
+		require.NoError(t, first.Verify())
+		require.NoError(t, second.Verify())
+
+		require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+		require.Nil(t, b.buf)
+		freeAll(t, first, second)
+	}
+
+	b := allocWith(t, text)
+	for _, at := range []int{-1, len(text) + 1} {
+		_, _, err := b.Split(at)
+		require.EqualError(t, err, ErrSeekOutOfBounds.Error())
+	}
+	freeAll(t, b)
+}
+
+func TestConcat(t *testing.T) {
+	parts := [][]byte{text[:10], text[10:11], text[11:]}
+	var bs []*Buffer
+	for _, p := range parts {
+		bs = append(bs, allocWith(t, p))
+	}
+
+	r, err := Concat(bs...)
+	require.NoError(t, err)
+	require.Equal(t, text, r.View())
+	require.Equal(t, len(text), r.Cap())
+	for i, b := range bs {
+		require.Equal(t, parts[i], b.View())
+	}
+	freeAll(t, r)
+
+	r, err = ConcatFree(bs[2], bs[0], bs[2])
+	require.NoError(t, err)
+	require.Equal(t, append(append(append([]byte{}, parts[2]...), parts[0]...), parts[2]...), r.View())
+	require.EqualError(t, bs[0].Verify(), ErrAlreadyFreed.Error())
+	require.EqualError(t, bs[2].Verify(), ErrAlreadyFreed.Error())
+	freeAll(t, r)
+
+	// A corrupt source fails the whole call, and nothing is freed.
+	c := allocWith(t, text)
+	c.canary[0] ^= 1
+	_, err = ConcatFree(bs[1], c)
+	require.EqualError(t, err, ErrDataCorrupted.Error())
+	require.NoError(t, bs[1].Verify())
+	c.canary[0] ^= 1
+	freeAll(t, bs[1], c)
+}
+
+func TestAppendFrom(t *testing.T) {
+	parts := [][]byte{text[:5], text[5:20], text[20:]}
+	b, err := Alloc(len(text))
+	require.NoError(t, err)
+
+	for _, p := range parts {
+		src := allocWith(t, p)
+		n, err := b.AppendFrom(src)
+		require.NoError(t, err)
+		require.Equal(t, len(p), n)
+		require.Equal(t, p, src.View())
+		freeAll(t, src)
+	}
+	require.Equal(t, text, b.View())
+
+	src := allocWith(t, text[:1])
+	n, err := b.AppendFrom(src)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Zero(t, n)
+	require.Equal(t, text, b.View())
+	freeAll(t, b, src)
+}
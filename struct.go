@@ -0,0 +1,104 @@
+package mlock
+
+import (
+	"bytes"
+	"sort"
+)
+
+// StructBuffer holds several named, fixed-size fields in a single protected Buffer. Each
+// field is preceded by its own canary, so overflowing one field corrupts the canary of
+// the field after it. The last field is followed directly by the rear guard page.
+type StructBuffer struct {
+	b        *Buffer
+	fields   map[string][]byte
+	canaries [][]byte // canaries between fields, the first field uses the Buffer's canary
+}
+
+// AllocStruct allocates a StructBuffer with a field of the requested number of bytes for
+// each name in fields. Fields are laid out in sorted name order.
+//
+// Like Alloc, the returned StructBuffer must be freed manually by calling its Free()
+// method.
+//
+// AllocStruct panics if fields is empty or any of the field sizes are not positive.
+func AllocStruct(fields map[string]int) (*StructBuffer, error) {
+	if len(fields) == 0 {
+		panic("no fields requested")
+	}
+
+	names := make([]string, 0, len(fields))
+	total := (len(fields) - 1) * CanarySize
+	for name, size := range fields {
+		if size <= 0 {
+			panic("non-positive field size requested")
+		}
+		names = append(names, name)
+		total += size
+	}
+	sort.Strings(names)
+
+	b, err := Alloc(total)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StructBuffer{
+		b:      b,
+		fields: make(map[string][]byte, len(names)),
+	}
+	data := b.data
+	for i, name := range names {
+		if i > 0 {
+			c := data[:CanarySize]
+			if n := copy(c, canary[:]); n != CanarySize {
+				panic("copied wrong number of bytes to canary")
+			}
+			s.canaries = append(s.canaries, c)
+			data = data[CanarySize:]
+		}
+
+		size := fields[name]
+		s.fields[name] = data[:size:size]
+		data = data[size:]
+	}
+	b.i = len(b.data)
+
+	return s, nil
+}
+
+// Field returns the named field. Like Buffer.View, the returned slice may be read from or
+// written to, but must not be copied outside the StructBuffer.
+//
+// If the StructBuffer is corrupt or freed, or has no such field, a nil buffer is returned.
+func (s *StructBuffer) Field(name string) []byte {
+	if err := s.Verify(); err != nil {
+		return nil
+	}
+	return s.fields[name]
+}
+
+// Verify checks the integrity of every field's canary.
+func (s *StructBuffer) Verify() error {
+	s.b.mu.Lock()
+	defer s.b.unlock()
+	if err := s.b.canaryCheck(); err != nil {
+		return err
+	}
+	for _, c := range s.canaries {
+		if !bytes.Equal(c, canary[:]) {
+			s.b.event = EventCorruption
+			return ErrDataCorrupted
+		}
+	}
+	return nil
+}
+
+// Free wipes every field and releases the StructBuffer back to the system.
+func (s *StructBuffer) Free() error {
+	if err := s.b.Free(); err != nil {
+		return err
+	}
+	s.fields = nil
+	s.canaries = nil
+	return nil
+}
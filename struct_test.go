@@ -0,0 +1,65 @@
+package mlock
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocStruct(t *testing.T) {
+	fields := map[string]int{
+		"signing":    64,
+		"encryption": 32,
+		"salt":       16,
+	}
+	s, err := AllocStruct(fields)
+	require.NoError(t, err)
+	require.Equal(t, 64+32+16+2*CanarySize, s.b.Cap())
+
+	values := make(map[string][]byte)
+	for name, size := range fields {
+		f := s.Field(name)
+		require.Len(t, f, size)
+
+		v := make([]byte, size)
+		_, err = rand.Read(v)
+		require.NoError(t, err)
+		copy(f, v)
+		values[name] = v
+	}
+	require.NoError(t, s.Verify())
+
+	for name, v := range values {
+		require.Equal(t, v, s.Field(name))
+	}
+	require.Nil(t, s.Field("missing"))
+
+	err = s.Free()
+	require.NoError(t, err)
+	require.Nil(t, s.Field("salt"))
+	err = s.Free()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+func TestAllocStructFieldOverflow(t *testing.T) {
+	s, err := AllocStruct(map[string]int{"a": 8, "b": 8, "c": 8})
+	require.NoError(t, err)
+	require.NoError(t, s.Verify())
+
+	// "a" is followed by the canary of "b", so writing one byte past it is detected.
+	a := s.Field("a")
+	require.Equal(t, len(a), cap(a))
+	over := s.b.data[:len(a)+1]
+	over[len(a)]++
+
+	require.EqualError(t, s.Verify(), ErrDataCorrupted.Error())
+	require.Nil(t, s.Field("b"))
+
+	over[len(a)]--
+	require.NoError(t, s.Verify())
+	require.NotNil(t, s.Field("b"))
+
+	err = s.Free()
+	require.NoError(t, err)
+}
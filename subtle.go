@@ -0,0 +1,167 @@
+package mlock
+
+import (
+	"crypto/subtle"
+	"unsafe"
+)
+
+// Swap exchanges the data of a and b, which must have the same length. Both buffers are
+// checked for integrity first. The data is exchanged in place, and never copied outside
+// of the two buffers.
+func Swap(a, b *Buffer) error {
+	return ConditionalSwap(a, b, true)
+}
+
+// ConditionalSwap exchanges the data of a and b if swap is true, and leaves both
+// unchanged otherwise. Both cases perform the same operations on the same memory, so the
+// choice is not revealed by timing. a and b must have the same length.
+func ConditionalSwap(a, b *Buffer, swap bool) error {
+	defer lock(a, b)()
+	if err := a.writableCheck(); err != nil {
+		return err
+	}
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+	if a.i != b.i {
+		return ErrLengthMismatch
+	}
+
+	a.unseal(a.i)
+	if b != a {
+		b.unseal(b.i)
+	}
+	m := mask(bit(swap))
+	x, y := a.data[:a.i], b.data[:b.i]
+	for i := range x {
+		t := m & (x[i] ^ y[i])
+		x[i] ^= t
+		y[i] ^= t
+	}
+	// Both buffers are re-encrypted whether or not they were swapped, so the choice is
+	// not revealed by timing either.
+	a.reseal()
+	if b != a {
+		b.reseal()
+	}
+	a.setLen(a.i)
+	b.setLen(b.i)
+	return nil
+}
+
+// Select copies the data of a into dst if choose is true, and the data of b otherwise,
+// setting dst's length to match. Both copies are always performed with
+// subtle.ConstantTimeCopy, so the choice is not revealed by timing. a and b must have the
+// same length, and dst must be able to hold it. dst may be the same Buffer as a or b.
+func Select(dst, a, b *Buffer, choose bool) error {
+	defer lock(dst, a, b)()
+	if err := dst.writableCheck(); err != nil {
+		return err
+	}
+	for _, buf := range []*Buffer{a, b} {
+		if err := buf.canaryCheck(); err != nil {
+			return err
+		}
+	}
+	if a.i != b.i {
+		return ErrLengthMismatch
+	}
+	n := a.i
+	if n > len(dst.data) {
+		return ErrBufferFull
+	}
+
+	a.unseal(n)
+	if b != a {
+		b.unseal(n)
+	}
+	if dst != a && dst != b {
+		dst.unseal(dst.i)
+	}
+	v := bit(choose)
+	out := dst.data[:n]
+	subtle.ConstantTimeCopy(v, out, a.data[:n])
+	subtle.ConstantTimeCopy(1-v, out, b.data[:n])
+	if a != dst {
+		a.seal(n)
+	}
+	if b != a && b != dst {
+		b.seal(n)
+	}
+	dst.reseal()
+	dst.setLen(n)
+	return nil
+}
+
+// Equal reports whether b and other hold the same data. The comparison takes time
+// depending only on the length of the data, not its contents.
+func (b *Buffer) Equal(other *Buffer) (bool, error) {
+	defer lock(b, other)()
+	return b.equal(other)
+}
+
+// EqualFresh is like Equal, but first checks that b's generation is still gen, returning
+// ErrStale if b has been changed since gen was read from Generation. This catches
+// comparing against a buffer that changed between when it was checked and when it is
+// used.
+func (b *Buffer) EqualFresh(other *Buffer, gen uint64) (bool, error) {
+	defer lock(b, other)()
+	if err := b.canaryCheck(); err != nil {
+		return false, err
+	}
+	if b.gen != gen {
+		return false, ErrStale
+	}
+	return b.equal(other)
+}
+
+func (b *Buffer) equal(other *Buffer) (bool, error) {
+	if err := b.canaryCheck(); err != nil {
+		return false, err
+	}
+	if err := other.canaryCheck(); err != nil {
+		return false, err
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+	if other != b {
+		other.unseal(other.i)
+		defer other.seal(other.i)
+	}
+	return subtle.ConstantTimeCompare(b.data[:b.i], other.data[:other.i]) == 1, nil
+}
+
+// CompareAndWipe compares the buffer's data to expected in constant time, and if they are
+// equal, wipes the buffer, as Zero does, and returns true. Otherwise, the buffer is left
+// as it was and false is returned. The comparison and wipe happen under one lock, so a
+// single-use secret, such as a one-time code, can be checked and consumed at once, and
+// only one of several concurrent callers can consume it.
+func (b *Buffer) CompareAndWipe(expected []byte) (bool, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return false, err
+	}
+
+	b.unseal(b.i)
+	if subtle.ConstantTimeCompare(b.data[:b.i], expected) != 1 {
+		b.seal(b.i)
+		return false, nil
+	}
+	if err := b.clear(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// bit returns 1 if v is true, and 0 otherwise. It reads the byte a bool is stored as,
+// which is always 0 or 1, rather than branching on v, which may be secret.
+func bit(v bool) int {
+	return int(*(*uint8)(unsafe.Pointer(&v)))
+}
+
+// mask returns 0xff if x is 1, and 0 if x is 0, computed arithmetically as crypto/subtle
+// does.
+func mask(x int) byte {
+	return byte(-x)
+}
@@ -0,0 +1,167 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwap(t *testing.T) {
+	x, y := []byte("first secret"), []byte("other secret")
+	a, b := allocWith(t, x), allocWith(t, y)
+
+	err := Swap(a, b)
+	require.NoError(t, err)
+	require.Equal(t, y, a.View())
+	require.Equal(t, x, b.View())
+
+	err = ConditionalSwap(a, b, false)
+	require.NoError(t, err)
+	require.Equal(t, y, a.View())
+	require.Equal(t, x, b.View())
+
+	err = ConditionalSwap(a, b, true)
+	require.NoError(t, err)
+	require.Equal(t, x, a.View())
+	require.Equal(t, y, b.View())
+
+	err = a.Rewind(len(x) - 1)
+	require.NoError(t, err)
+	err = Swap(a, b)
+	require.EqualError(t, err, ErrLengthMismatch.Error())
+	require.Equal(t, x[:len(x)-1], a.View())
+	require.Equal(t, y, b.View())
+
+	freeAll(t, a)
+	err = Swap(a, b)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+	freeAll(t, b)
+}
+
+func TestMask(t *testing.T) {
+	require.Equal(t, 1, bit(true))
+	require.Equal(t, 0, bit(false))
+	require.Equal(t, byte(0xff), mask(1))
+	require.Equal(t, byte(0), mask(0))
+}
+
+func TestSelect(t *testing.T) {
+	x, y := []byte("first secret"), []byte("other secret")
+	a, b := allocWith(t, x), allocWith(t, y)
+	dst, err := Alloc(2 * len(x))
+	require.NoError(t, err)
+
+	err = Select(dst, a, b, true)
+	require.NoError(t, err)
+	require.Equal(t, x, dst.View())
+
+	err = Select(dst, a, b, false)
+	require.NoError(t, err)
+	require.Equal(t, y, dst.View())
+
+	err = Select(a, a, b, true)
+	require.NoError(t, err)
+	require.Equal(t, x, a.View())
+	err = Select(a, a, b, false)
+	require.NoError(t, err)
+	require.Equal(t, y, a.View())
+
+	small, err := Alloc(len(x) - 1)
+	require.NoError(t, err)
+	err = Select(small, a, b, true)
+	require.EqualError(t, err, ErrBufferFull.Error())
+	require.Zero(t, small.Len())
+
+	err = b.Rewind(len(y) - 1)
+	require.NoError(t, err)
+	err = Select(dst, a, b, true)
+	require.EqualError(t, err, ErrLengthMismatch.Error())
+
+	freeAll(t, a, b, dst, small)
+}
+
+// allocWith allocates a buffer holding a copy of data.
+func allocWith(t *testing.T, data []byte) *Buffer {
+	b, err := Alloc(len(data))
+	require.NoError(t, err)
+	_, err = b.Write(data)
+	require.NoError(t, err)
+	return b
+}
+
+func freeAll(t *testing.T, bs ...*Buffer) {
+	for _, b := range bs {
+		require.NoError(t, b.Free())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := allocWith(t, text)
+	b := allocWith(t, text)
+	c := allocWith(t, text[:len(text)-1])
+	defer freeAll(t, a, b, c)
+
+	eq, err := a.Equal(b)
+	require.NoError(t, err)
+	require.True(t, eq)
+	eq, err = a.Equal(a)
+	require.NoError(t, err)
+	require.True(t, eq)
+	eq, err = a.Equal(c)
+	require.NoError(t, err)
+	require.False(t, eq)
+}
+
+func TestEqualFresh(t *testing.T) {
+	a := allocWith(t, text)
+	b := allocWith(t, text)
+	defer freeAll(t, a, b)
+
+	gen := a.Generation()
+	eq, err := a.EqualFresh(b, gen)
+	require.NoError(t, err)
+	require.True(t, eq)
+
+	// Rewriting identical bytes still changes the generation.
+	require.NoError(t, a.Rewind(0))
+	_, err = a.Write(text)
+	require.NoError(t, err)
+	require.NotEqual(t, gen, a.Generation())
+
+	_, err = a.EqualFresh(b, gen)
+	require.EqualError(t, err, ErrStale.Error())
+
+	eq, err = a.EqualFresh(b, a.Generation())
+	require.NoError(t, err)
+	require.True(t, eq)
+}
+
+func TestCompareAndWipe(t *testing.T) {
+	b := allocWith(t, []byte("123456"))
+	data := b.data
+
+	ok, err := b.CompareAndWipe([]byte("123457"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, []byte("123456"), b.View())
+
+	ok, err = b.CompareAndWipe([]byte("12345"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, []byte("123456"), b.View())
+
+	ok, err = b.CompareAndWipe([]byte("123456"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Zero(t, b.Len())
+	require.Equal(t, make([]byte, len(data)), data)
+
+	// Once consumed, the code no longer matches.
+	ok, err = b.CompareAndWipe([]byte("123456"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	freeAll(t, b)
+	_, err = b.CompareAndWipe(nil)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
@@ -0,0 +1,72 @@
+package mlock
+
+import (
+	"sync"
+	"time"
+)
+
+var sweeper struct {
+	mu   sync.Mutex
+	stop chan struct{} // closed to stop the running sweeper, or nil if none is running
+	done chan struct{} // closed once the running sweeper has stopped
+}
+
+// StartSweeper starts a goroutine that calls Verify on every live Buffer once per
+// interval, so that corruption of a Buffer that is not being used is still found, and
+// logged as EventCorruption through the function set by SetLogger. Buffers that are
+// freed or expire during a sweep are skipped. Any sweeper already running is stopped
+// first.
+//
+// Each Buffer is locked while it is verified, so a sweep briefly contends with its other
+// users, and takes time proportional to the total size of all live Buffers in strict or
+// checksum mode. StartSweeper panics if interval is not positive.
+func StartSweeper(interval time.Duration) {
+	if interval <= 0 {
+		panic("non-positive sweep interval")
+	}
+
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	stopSweeper()
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	sweeper.stop, sweeper.done = stop, done
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				sweep()
+			}
+		}
+	}()
+}
+
+// StopSweeper stops the sweeper started by StartSweeper, if it is running, and waits for
+// any sweep in progress to finish.
+func StopSweeper() {
+	sweeper.mu.Lock()
+	defer sweeper.mu.Unlock()
+	stopSweeper()
+}
+
+func stopSweeper() {
+	if sweeper.stop == nil {
+		return
+	}
+	close(sweeper.stop)
+	<-sweeper.done
+	sweeper.stop, sweeper.done = nil, nil
+}
+
+// sweep verifies every live Buffer. Verify logs any corruption it finds, so the errors
+// themselves are not needed.
+func sweep() {
+	for _, b := range liveBuffers() {
+		_ = b.Verify()
+	}
+}
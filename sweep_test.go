@@ -0,0 +1,80 @@
+package mlock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweeper(t *testing.T) {
+	corrupt := make(chan *Buffer, 1)
+	SetLogger(func(event string, b *Buffer) {
+		if event != EventCorruption {
+			return
+		}
+		select {
+		case corrupt <- b:
+		default:
+		}
+	})
+	defer SetLogger(nil)
+
+	healthy := allocWith(t, text)
+	idle := allocWith(t, text)
+	idle.canary[0] ^= 1
+
+	StartSweeper(time.Millisecond)
+	select {
+	case b := <-corrupt:
+		require.Equal(t, idle, b)
+	case <-time.After(10 * time.Second):
+		t.Fatal("sweeper did not find the corrupted buffer")
+	}
+	StopSweeper()
+	StopSweeper()
+
+	idle.canary[0] ^= 1
+	freeAll(t, healthy, idle)
+}
+
+func TestPeriodicVerify(t *testing.T) {
+	events := make(chan string, 10)
+	SetLogger(func(event string, b *Buffer) {
+		if event != EventAlloc {
+			events <- event
+		}
+	})
+	defer SetLogger(nil)
+
+	for _, free := range []bool{false, true} {
+		opts := []Option{WithPeriodicVerify(time.Millisecond)}
+		if free {
+			opts = append(opts, WithFreeOnCorruption())
+		}
+		b, err := Alloc(len(text), opts...)
+		require.NoError(t, err)
+		b.mu.Lock()
+		b.canary[0] ^= 1
+		b.mu.Unlock()
+
+		select {
+		case event := <-events:
+			require.Equal(t, EventCorruption, event)
+		case <-time.After(10 * time.Second):
+			t.Fatal("periodic verify did not find the corrupted buffer")
+		}
+
+		if free {
+			require.Equal(t, EventFree, <-events)
+			require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+			continue
+		}
+		b.mu.Lock()
+		b.canary[0] ^= 1
+		b.mu.Unlock()
+		require.NoError(t, b.Free())
+		require.Equal(t, EventFree, <-events)
+		require.False(t, b.verifier.Stop(), "verifier still running after Free")
+	}
+}
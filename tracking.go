@@ -0,0 +1,157 @@
+package mlock
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var tracking int32 // set by EnableAllocTracking
+
+// registryShards is the number of shards of the registry of live Buffers. Every Alloc and
+// Free updates the registry, so it is split by allocation order to keep concurrent
+// allocations from contending on a single lock.
+const registryShards = 64
+
+// registry holds every live Buffer, with its site if tracked.
+var registry [registryShards]struct {
+	mu   sync.Mutex
+	bufs map[*Buffer]string
+	_    [48]byte // pad to a cache line, so neighboring shards do not share one
+}
+
+// EnableAllocTracking turns recording of the call stack that allocated each Buffer on or
+// off. While tracking is on, DumpLiveBuffers reports where every Buffer allocated since it
+// was turned on, and not yet freed, came from. Capturing stacks is expensive, so tracking
+// is intended for debugging leaks, and is off by default.
+//
+// Turning tracking off stops recording new Buffers, but Buffers already recorded remain
+// in the registry until they are freed.
+func EnableAllocTracking(enable bool) {
+	var v int32
+	if enable {
+		v = 1
+	}
+	atomic.StoreInt32(&tracking, v)
+}
+
+// DumpLiveBuffers returns a description of each tracked Buffer that has not been freed,
+// giving its capacity and the stack that allocated it, in allocation order.
+func DumpLiveBuffers() []string {
+	type entry struct {
+		id   uint64
+		site string
+	}
+	var entries []entry
+	for i := range registry {
+		s := &registry[i]
+		s.mu.Lock()
+		for b, site := range s.bufs {
+			if site != "" {
+				entries = append(entries, entry{b.id, site})
+			}
+		}
+		s.mu.Unlock()
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	dump := make([]string, len(entries))
+	for i, e := range entries {
+		dump[i] = e.site
+	}
+	return dump
+}
+
+// SetLabel attaches a label to the buffer, naming its purpose, such as "tls-session-key",
+// for ListLabels to report. The label is metadata, and must never contain the secret. It
+// is carried over by Realloc.
+func (b *Buffer) SetLabel(label string) {
+	b.mu.Lock()
+	defer b.unlock()
+	b.label = label
+}
+
+// Label returns the label set by SetLabel, or "" if there is none.
+func (b *Buffer) Label() string {
+	b.mu.Lock()
+	defer b.unlock()
+	return b.label
+}
+
+// ListLabels returns the label of every Buffer that has not been freed and has a label, in
+// allocation order, so that what is held in memory can be inventoried by purpose without
+// exposing any of it. Unlike DumpLiveBuffers, it does not need EnableAllocTracking.
+func ListLabels() []string {
+	bs := liveBuffers()
+	sort.Slice(bs, func(i, j int) bool { return bs[i].id < bs[j].id })
+
+	var labels []string
+	for _, b := range bs {
+		b.mu.Lock()
+		if b.buf != nil && b.label != "" {
+			labels = append(labels, b.label)
+		}
+		b.mu.Unlock()
+	}
+	return labels
+}
+
+// liveBuffers returns every Buffer that has not been freed, tracked or not.
+func liveBuffers() []*Buffer {
+	var bs []*Buffer
+	for i := range registry {
+		s := &registry[i]
+		s.mu.Lock()
+		for b := range s.bufs {
+			bs = append(bs, b)
+		}
+		s.mu.Unlock()
+	}
+	return bs
+}
+
+// register adds b to its shard of the registry, with the given site.
+func register(b *Buffer, site string) {
+	s := &registry[b.id%registryShards]
+	s.mu.Lock()
+	if s.bufs == nil {
+		s.bufs = make(map[*Buffer]string)
+	}
+	s.bufs[b] = site
+	s.mu.Unlock()
+}
+
+// track adds b to the registry, with the stack allocating it if tracking is enabled.
+func track(b *Buffer) {
+	if atomic.LoadInt32(&tracking) == 0 {
+		register(b, "")
+		return
+	}
+
+	pc := make([]uintptr, 32)
+	pc = pc[:runtime.Callers(3, pc)] // skip Callers, track and newBuffer
+	frames := runtime.CallersFrames(pc)
+
+	var site strings.Builder
+	fmt.Fprintf(&site, "buffer of %d bytes allocated at:\n", len(b.data))
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&site, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+
+	register(b, site.String())
+}
+
+// untrack removes b from the registry.
+func untrack(b *Buffer) {
+	s := &registry[b.id%registryShards]
+	s.mu.Lock()
+	delete(s.bufs, b)
+	s.mu.Unlock()
+}
@@ -0,0 +1,55 @@
+package mlock
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocTracking(t *testing.T) {
+	EnableAllocTracking(true)
+	defer EnableAllocTracking(false)
+
+	freed, err := Alloc(kb)
+	require.NoError(t, err)
+	leaked, err := Alloc(2 * kb)
+	require.NoError(t, err)
+	require.Len(t, DumpLiveBuffers(), 2)
+
+	require.NoError(t, freed.Free())
+	dump := DumpLiveBuffers()
+	require.Len(t, dump, 1)
+	require.True(t, strings.HasPrefix(dump[0], "buffer of 2048 bytes allocated at:\n"), dump[0])
+	require.Contains(t, dump[0], "TestAllocTracking")
+
+	require.NoError(t, leaked.Free())
+	require.Empty(t, DumpLiveBuffers())
+
+	EnableAllocTracking(false)
+	untracked, err := Alloc(kb)
+	require.NoError(t, err)
+	require.Empty(t, DumpLiveBuffers())
+	require.NoError(t, untracked.Free())
+}
+
+func TestLabels(t *testing.T) {
+	require.Empty(t, ListLabels())
+
+	a := allocWith(t, text)
+	b := allocWith(t, text)
+	unlabeled := allocWith(t, text)
+	a.SetLabel("tls-session-key")
+	b.SetLabel("db-password")
+	require.Equal(t, "tls-session-key", a.Label())
+	require.Empty(t, unlabeled.Label())
+	require.Equal(t, []string{"tls-session-key", "db-password"}, ListLabels())
+
+	a.SetLabel("tls-ticket-key")
+	require.Equal(t, []string{"tls-ticket-key", "db-password"}, ListLabels())
+
+	freeAll(t, a)
+	require.Equal(t, []string{"db-password"}, ListLabels())
+	freeAll(t, b, unlabeled)
+	require.Empty(t, ListLabels())
+}
@@ -0,0 +1,50 @@
+package mlock
+
+// Transform calls fn with the buffer's written data, for fn to change in place, such as
+// reversing or masking it. The buffer is checked for integrity both before and after fn
+// runs, so an overflow caused by a buggy fn is reported as ErrDataCorrupted.
+//
+// fn must not retain the slice, grow it, or write outside it. The buffer is locked while
+// fn runs, so fn must not call any of b's methods.
+func (b *Buffer) Transform(fn func(data []byte)) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.writableCheck(); err != nil {
+		return err
+	}
+
+	b.unseal(b.i)
+	fn(b.data[:b.i:b.i])
+	b.reseal()
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+	b.setLen(b.i)
+	return nil
+}
+
+// WithView calls fn with the buffer's written data, and returns fn's error. Unlike View,
+// the slice is only valid for the duration of the call, which makes it harder for it to
+// outlive the buffer. The buffer is checked for integrity both before and after fn runs,
+// and corruption found afterwards is reported as ErrDataCorrupted in place of fn's error.
+// For a Buffer allocated WithEncryptedAtRest, the data is decrypted in place for fn, and
+// encrypted again once fn returns.
+//
+// fn must not retain the slice or write outside it, and should not change the data; use
+// Transform for that. The buffer is locked while fn runs, so fn must not call any of b's
+// methods.
+func (b *Buffer) WithView(fn func(data []byte) error) error {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+
+	b.unseal(b.i)
+	err := fn(b.data[:b.i:b.i])
+	b.seal(b.i)
+	if err := b.canaryCheck(); err != nil {
+		return err
+	}
+	return err
+}
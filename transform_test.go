@@ -0,0 +1,55 @@
+package mlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransform(t *testing.T) {
+	b := allocWith(t, []byte("abcdef"))
+	defer freeAll(t, b)
+
+	err := b.Transform(func(data []byte) {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte("fedcba"), b.View())
+
+	// Corrupting the canary from within fn is detected.
+	err = b.Transform(func([]byte) {
+		b.canary[0] ^= 1
+	})
+	require.EqualError(t, err, ErrDataCorrupted.Error())
+	b.canary[0] ^= 1
+	require.NoError(t, b.Verify())
+}
+
+func TestWithView(t *testing.T) {
+	b := allocWith(t, text)
+	defer freeAll(t, b)
+
+	var seen int
+	err := b.WithView(func(data []byte) error {
+		require.Equal(t, text, data)
+		require.Equal(t, len(text), cap(data))
+		seen = len(data)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(text), seen)
+
+	err = b.WithView(func([]byte) error { return ErrStale })
+	require.EqualError(t, err, ErrStale.Error())
+
+	// Corruption during fn takes precedence over fn's own error.
+	err = b.WithView(func([]byte) error {
+		b.canary[0] ^= 1
+		return ErrStale
+	})
+	require.EqualError(t, err, ErrDataCorrupted.Error())
+	b.canary[0] ^= 1
+	require.NoError(t, b.Verify())
+}
@@ -0,0 +1,64 @@
+package mlock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTTL = 10 * time.Millisecond
+
+func TestTTL(t *testing.T) {
+	b, err := Alloc(len(text), WithTTL(testTTL))
+	require.NoError(t, err)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+	require.Equal(t, text, b.View())
+
+	require.Eventually(t, func() bool {
+		return b.Verify() == ErrExpired
+	}, time.Second, testTTL)
+
+	require.Equal(t, make([]byte, len(text)), b.data)
+	require.Nil(t, b.View())
+	require.Zero(t, b.Len())
+	_, err = b.Write(text)
+	require.EqualError(t, err, ErrExpired.Error())
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+func TestTTLFreeOnExpiry(t *testing.T) {
+	b, err := Alloc(len(text), WithTTL(testTTL), WithFreeOnExpiry())
+	require.NoError(t, err)
+
+	_, err = b.Write(text)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return b.Verify() == ErrExpired
+	}, time.Second, testTTL)
+
+	b.mu.Lock()
+	require.Nil(t, b.buf)
+	b.mu.Unlock()
+
+	err = b.Free()
+	require.EqualError(t, err, ErrExpired.Error())
+}
+
+func TestTTLStoppedByFree(t *testing.T) {
+	b, err := Alloc(len(text), WithTTL(testTTL))
+	require.NoError(t, err)
+
+	err = b.Free()
+	require.NoError(t, err)
+	require.False(t, b.ttl.Stop(), "timer still running after Free")
+
+	time.Sleep(2 * testTTL)
+	err = b.Verify()
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
@@ -0,0 +1,83 @@
+package mlock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+)
+
+// writeChunk is the most data written to an io.Writer in a single call by WriteTo.
+const writeChunk = 32 * 1024
+
+var _ io.WriterTo = (*Buffer)(nil)
+
+// WriteTo implements the io.WriterTo interface, writing the buffer's data to w. The data
+// is written in chunks, directly from the buffer.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	return b.WriteToContext(context.Background(), w)
+}
+
+// WriteToContext is like WriteTo, but checks ctx before writing each chunk, and returns
+// ctx's error once it is done. A single call to w.Write cannot be interrupted, so the
+// chunk being written when ctx is cancelled is completed first.
+func (b *Buffer) WriteToContext(ctx context.Context, w io.Writer) (int64, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return 0, err
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+
+	var total int64
+	for data := b.data[:b.i]; len(data) > 0; {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		chunk := data
+		if len(chunk) > writeChunk {
+			chunk = chunk[:writeChunk]
+		}
+		n, err := w.Write(chunk)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		if n < len(chunk) {
+			return total, io.ErrShortWrite
+		}
+		data = data[n:]
+	}
+	return total, nil
+}
+
+// Drain writes the buffer's data to w, as WriteTo does, and then frees the buffer whether
+// or not the write succeeded, so that the secret is delivered and destroyed in one call.
+// Any errors from writing and freeing are joined.
+func (b *Buffer) Drain(w io.Writer) (int64, error) {
+	n, err := b.WriteTo(w)
+	return n, errors.Join(err, b.Free())
+}
+
+// WriteToConn writes the buffer's data to c in a single sendmsg call, directly from the
+// buffer, so the data is never copied out of locked memory before the kernel takes it.
+// On a stream socket, the kernel may accept only part of the data, in which case the
+// number of bytes sent is returned with io.ErrShortWrite; a datagram or seqpacket socket
+// sends all of it or nothing.
+func (b *Buffer) WriteToConn(c *net.UnixConn) (int, error) {
+	b.mu.Lock()
+	defer b.unlock()
+	if err := b.canaryCheck(); err != nil {
+		return 0, err
+	}
+	b.unseal(b.i)
+	defer b.seal(b.i)
+
+	n, _, err := c.WriteMsgUnix(b.data[:b.i], nil, nil)
+	if err == nil && n < b.i {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
@@ -0,0 +1,47 @@
+package mlock
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// unixConnPair returns a connected pair of unix sockets of the given type.
+func unixConnPair(t *testing.T, typ int) (a, b *net.UnixConn) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, typ, 0)
+	require.NoError(t, err)
+
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := net.FileConn(f)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		conns[i] = c.(*net.UnixConn)
+		t.Cleanup(func() { conns[i].Close() })
+	}
+	return conns[0], conns[1]
+}
+
+func TestWriteToConn(t *testing.T) {
+	for _, typ := range []int{syscall.SOCK_STREAM, syscall.SOCK_SEQPACKET} {
+		src, dst := unixConnPair(t, typ)
+		b := allocWith(t, text)
+
+		n, err := b.WriteToConn(src)
+		require.NoError(t, err)
+		require.Equal(t, len(text), n)
+
+		got := make([]byte, len(text)+1)
+		n, err = dst.Read(got)
+		require.NoError(t, err)
+		require.Equal(t, text, got[:n])
+
+		freeAll(t, b)
+		_, err = b.WriteToConn(src)
+		require.EqualError(t, err, ErrAlreadyFreed.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package mlock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTo(t *testing.T) {
+	b, err := Alloc(3*writeChunk + 1)
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte{0xab}, b.Cap())
+	_, err = b.Write(data)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	n, err := b.WriteTo(&out)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+	require.Equal(t, data, out.Bytes())
+
+	err = b.Free()
+	require.NoError(t, err)
+
+	_, err = b.WriteTo(&out)
+	require.EqualError(t, err, ErrAlreadyFreed.Error())
+}
+
+// blockingWriter blocks each write until it is released.
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	w.writing <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+func TestWriteToContext(t *testing.T) {
+	b, err := Alloc(3 * writeChunk)
+	require.NoError(t, err)
+	require.NoError(t, b.SetLen(b.Cap()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := blockingWriter{make(chan struct{}), make(chan struct{})}
+	type result struct {
+		n   int64
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		n, err := b.WriteToContext(ctx, w)
+		done <- result{n, err}
+	}()
+
+	<-w.writing
+	cancel()
+	close(w.release)
+
+	r := <-done
+	require.Equal(t, context.Canceled, r.err)
+	require.Equal(t, int64(writeChunk), r.n)
+
+	err = b.Free()
+	require.NoError(t, err)
+}
+
+// failingWriter accepts n bytes, and then fails.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+var errWrite = errors.New("write failed")
+
+func TestDrain(t *testing.T) {
+	b := allocWith(t, text)
+	var out bytes.Buffer
+	n, err := b.Drain(&out)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(text)), n)
+	require.Equal(t, text, out.Bytes())
+	require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+
+	b = allocWith(t, text)
+	n, err = b.Drain(&failingWriter{n: 3})
+	require.True(t, errors.Is(err, errWrite))
+	require.Equal(t, int64(3), n)
+	require.EqualError(t, b.Verify(), ErrAlreadyFreed.Error())
+
+	// Draining a freed buffer reports both failures.
+	_, err = b.Drain(&out)
+	require.True(t, errors.Is(err, ErrAlreadyFreed))
+}